@@ -0,0 +1,469 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/c00d-ide/c00d/internal/security"
+)
+
+// Archive formats
+const (
+	Zip   = "zip"
+	TarGz = "tar.gz"
+	Tar   = "tar"
+)
+
+var (
+	// ErrFormat is returned for archives that aren't zip, tar or tar.gz
+	ErrFormat = errors.New("unsupported archive format")
+	// ErrTooLarge is returned when an archive unpacks to more than the limit
+	ErrTooLarge = errors.New("archive exceeds the extract size limit")
+)
+
+// FormatOf returns the archive format of a file name, or "" if unknown
+func FormatOf(name string) string {
+	name = strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return Zip
+	case strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return TarGz
+	case strings.HasSuffix(name, ".tar"):
+		return Tar
+	}
+	return ""
+}
+
+// Write streams an archive of dir to w. Entries are named relative to
+// dir, under a top-level folder called prefix. skip is called with the full
+// path of every entry below dir and leaves it, and anything under it, out
+// when it returns true. Files that can't be opened are left out; once an
+// entry's header is written, failing to read the rest of it doesn't stop
+// the archive.
+func Write(w io.Writer, format, dir, prefix string, skip func(fullPath string, isDir bool) bool) error {
+	// f is the opened file for regular files, link the target for symlinks
+	var add func(name string, info fs.FileInfo, link string, f *os.File) error
+	var finish func() error
+
+	switch format {
+	case Zip:
+		zw := zip.NewWriter(w)
+		add = func(name string, info fs.FileInfo, link string, f *os.File) error {
+			hdr, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			hdr.Name = name
+			if info.IsDir() {
+				hdr.Name += "/"
+			} else {
+				hdr.Method = zip.Deflate
+			}
+			entry, err := zw.CreateHeader(hdr)
+			if err != nil || info.IsDir() {
+				return err
+			}
+			if f == nil {
+				// zip stores a symlink's target as its content
+				_, err = io.WriteString(entry, link)
+				return err
+			}
+			_, err = writeContent(entry, f, info.Size())
+			return err
+		}
+		finish = zw.Close
+
+	case TarGz, Tar:
+		var gz *gzip.Writer
+		out := w
+		if format == TarGz {
+			gz = gzip.NewWriter(w)
+			out = gz
+		}
+		tw := tar.NewWriter(out)
+		add = func(name string, info fs.FileInfo, link string, f *os.File) error {
+			hdr, err := tar.FileInfoHeader(info, link)
+			if err != nil {
+				return err
+			}
+			hdr.Name = name
+			if info.IsDir() {
+				hdr.Name += "/"
+			}
+			if err := tw.WriteHeader(hdr); err != nil || f == nil {
+				return err
+			}
+			// The header promised hdr.Size bytes; pad a file that shrank
+			n, err := writeContent(tw, f, hdr.Size)
+			if err == nil && n < hdr.Size {
+				_, err = io.CopyN(tw, zeros{}, hdr.Size-n)
+			}
+			return err
+		}
+		finish = func() error {
+			if err := tw.Close(); err != nil {
+				return err
+			}
+			if gz != nil {
+				return gz.Close()
+			}
+			return nil
+		}
+
+	default:
+		return ErrFormat
+	}
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Unreadable entries are left out
+		}
+		if p != dir && skip != nil && skip(p, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(dir, p)
+		name := path.Join(prefix, filepath.ToSlash(rel))
+
+		switch {
+		case info.IsDir():
+			return add(name, info, "", nil)
+		case info.Mode()&fs.ModeSymlink != 0:
+			link, err := os.Readlink(p)
+			if err != nil {
+				return nil
+			}
+			return add(name, info, link, nil)
+		case info.Mode().IsRegular():
+			// Open before the header goes out, so unreadable files are left out
+			f, err := os.Open(p)
+			if err != nil {
+				return nil
+			}
+			defer f.Close()
+			return add(name, info, "", f)
+		}
+		// Sockets, pipes and devices can't be archived
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return finish()
+}
+
+// writeContent copies up to size bytes of f into an archive entry and
+// returns how many it wrote. Errors reading f end the copy early; only
+// errors writing to w are returned.
+func writeContent(w io.Writer, f *os.File, size int64) (int64, error) {
+	return io.Copy(w, io.LimitReader(readUntilError{f}, size))
+}
+
+// readUntilError reads from r, ending at the first error as if at EOF
+type readUntilError struct{ r io.Reader }
+
+func (r readUntilError) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// zeros is an endless reader of zero bytes
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// Result lists what an extraction did
+type Result struct {
+	Extracted []string `json:"extracted"`
+	Skipped   []Skip   `json:"skipped"`
+	Size      int64    `json:"size"`
+}
+
+// Skip is an archive entry that wasn't extracted
+type Skip struct {
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// entry is one member of an archive being extracted
+type entry struct {
+	name string
+	mode fs.FileMode
+	link string
+	open func() (io.ReadCloser, error)
+}
+
+// Extract unpacks the archive at archivePath into dest. Entries whose
+// paths would land outside dest (zip slip) or go through a symlink,
+// symlinks pointing outside it or through another symlink, and existing
+// files unless overwrite is set are skipped, as are entries for which
+// protected, if set, returns true. prepare, if set, is called before a file
+// or symlink is written to fullPath, where one may exist, and the function
+// it returns once it's written; callers lock and snapshot there. limit caps
+// the total unpacked size (-1 = no limit).
+func Extract(archivePath, dest string, overwrite bool, limit int64, protected func(fullPath string) bool, prepare func(fullPath string) (done func())) (*Result, error) {
+	format := FormatOf(archivePath)
+	if format == "" {
+		return nil, ErrFormat
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result := &Result{Extracted: []string{}, Skipped: []Skip{}}
+	x := &extractor{dest: dest, overwrite: overwrite, limit: limit, protected: protected, prepare: prepare, result: result}
+
+	switch format {
+	case Zip:
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		zr, err := zip.NewReader(f, info.Size())
+		if err != nil {
+			return nil, err
+		}
+		for _, zf := range zr.File {
+			e := entry{name: zf.Name, mode: zf.Mode(), open: zf.Open}
+			if e.mode&fs.ModeSymlink != 0 {
+				e.link, err = readLink(zf)
+				if err != nil {
+					return result, err
+				}
+			}
+			if err := x.extract(e); err != nil {
+				return result, err
+			}
+		}
+
+	case TarGz, Tar:
+		var r io.Reader = f
+		if format == TarGz {
+			gz, err := gzip.NewReader(f)
+			if err != nil {
+				return nil, err
+			}
+			defer gz.Close()
+			r = gz
+		}
+		tr := tar.NewReader(r)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return result, err
+			}
+			e := entry{name: hdr.Name, mode: hdr.FileInfo().Mode(), link: hdr.Linkname}
+			e.open = func() (io.ReadCloser, error) { return io.NopCloser(tr), nil }
+			if hdr.Typeflag == tar.TypeLink {
+				// Hard links would need a second pass to copy their target
+				result.Skipped = append(result.Skipped, Skip{Name: hdr.Name, Error: "hard links are not supported"})
+				continue
+			}
+			if err := x.extract(e); err != nil {
+				return result, err
+			}
+		}
+	}
+	return result, nil
+}
+
+func readLink(zf *zip.File) (string, error) {
+	rc, err := zf.Open()
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	link, err := io.ReadAll(io.LimitReader(rc, 4096))
+	return string(link), err
+}
+
+type extractor struct {
+	dest      string
+	overwrite bool
+	limit     int64
+	protected func(fullPath string) bool
+	prepare   func(fullPath string) (done func())
+	result    *Result
+}
+
+func (x *extractor) skip(name, reason string) {
+	x.result.Skipped = append(x.result.Skipped, Skip{Name: name, Error: reason})
+}
+
+func (x *extractor) extract(e entry) error {
+	name := strings.ReplaceAll(e.name, `\`, "/")
+	target := filepath.Join(x.dest, filepath.FromSlash(name))
+	if path.IsAbs(name) || security.Check(x.dest, target, false) != nil {
+		x.skip(e.name, "path outside destination")
+		return nil
+	}
+	if target == x.dest {
+		return nil
+	}
+	// A symlink extracted earlier must not redirect later entries outside
+	if x.escapes(x.dest, path.Dir(name)) {
+		x.skip(e.name, "path goes through a symlink")
+		return nil
+	}
+	if x.protected != nil && x.protected(target) {
+		x.skip(e.name, "access denied")
+		return nil
+	}
+	if x.prepare != nil && !e.mode.IsDir() {
+		defer x.prepare(target)()
+	}
+
+	switch {
+	case e.mode.IsDir():
+		if err := os.MkdirAll(target, 0755); err != nil {
+			x.skip(e.name, err.Error())
+		}
+		return nil
+
+	case e.mode&fs.ModeSymlink != 0:
+		link := strings.ReplaceAll(e.link, `\`, "/")
+		if path.IsAbs(link) || filepath.IsAbs(e.link) || x.escapes(filepath.Dir(target), link) {
+			x.skip(e.name, "symlink points outside destination")
+			return nil
+		}
+		if !x.replaceable(e.name, target) {
+			return nil
+		}
+		os.MkdirAll(filepath.Dir(target), 0755)
+		os.Remove(target)
+		if err := os.Symlink(e.link, target); err != nil {
+			x.skip(e.name, err.Error())
+			return nil
+		}
+
+	case e.mode.IsRegular():
+		if !x.replaceable(e.name, target) {
+			return nil
+		}
+		if err := x.writeFile(e, target); err != nil {
+			if errors.Is(err, ErrTooLarge) {
+				return err
+			}
+			x.skip(e.name, err.Error())
+			return nil
+		}
+
+	default:
+		x.skip(e.name, "unsupported entry type")
+		return nil
+	}
+
+	x.result.Extracted = append(x.result.Extracted, filepath.ToSlash(name))
+	return nil
+}
+
+// replaceable reports whether target may be written, recording a skip if not
+func (x *extractor) replaceable(name, target string) bool {
+	info, err := os.Lstat(target)
+	if err != nil {
+		return true
+	}
+	if info.IsDir() {
+		x.skip(name, "a directory exists at this path")
+		return false
+	}
+	if !x.overwrite {
+		x.skip(name, "file already exists")
+		return false
+	}
+	return true
+}
+
+func (x *extractor) writeFile(e entry, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	rc, err := e.open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var r io.Reader = rc
+	if x.limit > 0 {
+		// Read one byte past the limit to tell a full archive from an oversized one
+		r = io.LimitReader(rc, x.limit-x.result.Size+1)
+	}
+
+	perm := e.mode.Perm()
+	if perm == 0 {
+		perm = 0644 // Archives made without Unix modes
+	}
+	os.Remove(target) // Don't write through an existing symlink
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm|0600)
+	if err != nil {
+		return err
+	}
+	n, err := io.Copy(out, r)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	x.result.Size += n
+	if x.limit > 0 && x.result.Size > x.limit {
+		os.Remove(target)
+		return ErrTooLarge
+	}
+	if err != nil {
+		os.Remove(target)
+		return fmt.Errorf("%s: %w", e.name, err)
+	}
+	return nil
+}
+
+// escapes reports whether walking the slash-separated path rel from dir
+// leaves dest or passes through a symlink. Symlinks aren't followed: they
+// may have come from the archive itself, and a chain of them can climb out
+// one ".." at a time while each looks harmless on its own.
+func (x *extractor) escapes(dir, rel string) bool {
+	cur := dir
+	for _, name := range strings.Split(rel, "/") {
+		switch name {
+		case "", ".":
+			continue
+		case "..":
+			cur = filepath.Dir(cur)
+		default:
+			cur = filepath.Join(cur, name)
+		}
+		if !security.WithinDir(x.dest, cur) {
+			return true
+		}
+		if info, err := os.Lstat(cur); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,190 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/c00d-ide/c00d/internal/security"
+)
+
+type tarEntry struct {
+	name, link string
+	dir        bool
+}
+
+func writeTar(t *testing.T, entries []tarEntry) string {
+	t.Helper()
+	archivePath := filepath.Join(t.TempDir(), "test.tar")
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+	for _, e := range entries {
+		hdr := &tar.Header{Name: e.name, Mode: 0644, Typeflag: tar.TypeReg}
+		switch {
+		case e.dir:
+			hdr.Typeflag, hdr.Mode = tar.TypeDir, 0755
+		case e.link != "":
+			hdr.Typeflag, hdr.Linkname = tar.TypeSymlink, e.link
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return archivePath
+}
+
+func skipped(result *Result, name string) bool {
+	for _, s := range result.Skipped {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestExtractSymlinkChain(t *testing.T) {
+	// Each link climbs one level, which stays inside dest on its own, but
+	// a/l1/l2 resolves through a/l1 to the parent of dest
+	archivePath := writeTar(t, []tarEntry{
+		{name: "a/", dir: true},
+		{name: "a/l1", link: ".."},
+		{name: "a/l1/l2", link: ".."},
+		{name: "l2/escaped"},
+	})
+	dest := filepath.Join(t.TempDir(), "dest")
+	os.Mkdir(dest, 0755)
+
+	result, err := Extract(archivePath, dest, false, -1, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !skipped(result, "a/l1/l2") {
+		t.Errorf("a/l1/l2 was extracted through a/l1: %+v", result)
+	}
+	if info, err := os.Lstat(filepath.Join(dest, "l2")); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		t.Error("dest/l2 was created as a symlink")
+	}
+	if _, err := os.Lstat(filepath.Join(filepath.Dir(dest), "escaped")); err == nil {
+		t.Error("a file was written outside dest")
+	}
+}
+
+func TestExtractLinkTargetThroughSymlink(t *testing.T) {
+	// a/l1 points at dest itself, so a/l1/.. is its parent even though the
+	// target reads as a/ lexically
+	archivePath := writeTar(t, []tarEntry{
+		{name: "a/", dir: true},
+		{name: "a/l1", link: ".."},
+		{name: "up", link: "a/l1/.."},
+	})
+	dest := filepath.Join(t.TempDir(), "dest")
+	os.Mkdir(dest, 0755)
+
+	result, err := Extract(archivePath, dest, false, -1, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !skipped(result, "up") {
+		t.Errorf("up was extracted: %+v", result)
+	}
+}
+
+func TestExtractSymlinkInside(t *testing.T) {
+	archivePath := writeTar(t, []tarEntry{
+		{name: "a/", dir: true},
+		{name: "a/file"},
+		{name: "a/link", link: "file"},
+		{name: "b", link: "a/file"},
+	})
+	dest := t.TempDir()
+
+	result, err := Extract(archivePath, dest, false, -1, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Skipped) != 0 {
+		t.Errorf("skipped %+v", result.Skipped)
+	}
+	if link, err := os.Readlink(filepath.Join(dest, "b")); err != nil || link != "a/file" {
+		t.Errorf("b -> %q, %v", link, err)
+	}
+}
+
+func TestExtractProtected(t *testing.T) {
+	archivePath := writeTar(t, []tarEntry{
+		{name: ".c00d/c00d.db"},
+		{name: "file"},
+	})
+	dest := t.TempDir()
+	dataDir := filepath.Join(dest, ".c00d")
+	protected := func(fullPath string) bool { return security.WithinDir(dataDir, fullPath) }
+
+	result, err := Extract(archivePath, dest, true, -1, protected, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !skipped(result, ".c00d/c00d.db") {
+		t.Errorf(".c00d/c00d.db wasn't skipped: %+v", result)
+	}
+	if _, err := os.Stat(filepath.Join(dest, "file")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestWriteSkipsUnreadable(t *testing.T) {
+	if os.Getuid() == 0 {
+		t.Skip("root can read any file")
+	}
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "ok.txt"), []byte("ok"), 0644)
+	os.WriteFile(filepath.Join(dir, "secret.txt"), []byte("secret"), 0000)
+
+	var buf bytes.Buffer
+	if err := Write(&buf, Tar, dir, "out", nil); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	tr := tar.NewReader(&buf)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	if strings.Join(names, ",") != "out/,out/ok.txt" {
+		t.Errorf("entries %v", names)
+	}
+}
+
+func TestWriteContentShortFile(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "short")
+	os.WriteFile(p, []byte("abc"), 0644)
+	f, err := os.Open(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	// The file shrank from 10 bytes after its header was written
+	var buf bytes.Buffer
+	n, err := writeContent(&buf, f, 10)
+	if err != nil || n != 3 || buf.String() != "abc" {
+		t.Errorf("wrote %d %q, %v", n, buf.String(), err)
+	}
+}
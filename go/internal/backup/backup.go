@@ -0,0 +1,112 @@
+package backup
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/c00d-ide/c00d/internal/config"
+)
+
+// ErrNotFound is returned when a backup doesn't exist
+var ErrNotFound = errors.New("backup not found")
+
+// idFormat names backups by save time, so they sort oldest first
+const idFormat = "20060102T150405.000000000Z"
+
+// Backup is a saved previous version of a file
+type Backup struct {
+	ID        string `json:"id"`
+	Size      int64  `json:"size"`
+	CreatedAt string `json:"created_at"`
+}
+
+// dir returns the directory holding backups of the file at relPath in the
+// data directory of its workspace
+func dir(dataDir, relPath string) string {
+	return filepath.Join(dataDir, "backups", filepath.FromSlash(relPath))
+}
+
+// Save copies the current content of fullPath into the backups of relPath
+// and prunes all but the newest files.backups versions. A file that doesn't
+// exist yet has nothing to back up.
+func Save(dataDir, relPath, fullPath string) error {
+	keep := config.C.Files.Backups
+	if keep <= 0 {
+		return nil
+	}
+
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	backupDir := dir(dataDir, relPath)
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return err
+	}
+	id := time.Now().UTC().Format(idFormat)
+	if err := os.WriteFile(filepath.Join(backupDir, id), content, 0600); err != nil {
+		return err
+	}
+
+	backups, err := List(dataDir, relPath)
+	if err != nil {
+		return err
+	}
+	for i := keep; i < len(backups); i++ {
+		os.Remove(filepath.Join(backupDir, backups[i].ID))
+	}
+	return nil
+}
+
+// List returns the backups of relPath, newest first
+func List(dataDir, relPath string) ([]Backup, error) {
+	entries, err := os.ReadDir(dir(dataDir, relPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []Backup{}, nil
+		}
+		return nil, err
+	}
+
+	backups := []Backup{}
+	for _, entry := range entries {
+		created, err := time.Parse(idFormat, entry.Name())
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, Backup{
+			ID:        entry.Name(),
+			Size:      info.Size(),
+			CreatedAt: created.Format(time.RFC3339),
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].ID > backups[j].ID
+	})
+	return backups, nil
+}
+
+// Read returns the content of a backup
+func Read(dataDir, relPath, id string) ([]byte, error) {
+	if _, err := time.Parse(idFormat, id); err != nil || strings.ContainsAny(id, `/\`) {
+		return nil, ErrNotFound
+	}
+	content, err := os.ReadFile(filepath.Join(dir(dataDir, relPath), id))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return content, err
+}
@@ -14,6 +14,9 @@ type Config struct {
 	Password string `yaml:"password"`
 	DataDir  string `yaml:"data_dir"`
 
+	// Further project roots served next to base_path
+	Workspaces []Workspace `yaml:"workspaces"`
+
 	AI struct {
 		Provider   string `yaml:"provider"` // c00d, anthropic, openai, ollama
 		LicenseKey string `yaml:"license_key"`
@@ -28,13 +31,62 @@ type Config struct {
 		TabSize  int    `yaml:"tab_size"`
 	} `yaml:"editor"`
 
+	Files struct {
+		Backups     int   `yaml:"backups"`       // Previous versions kept per file when history is off (0 = off)
+		MaxReadSize int64 `yaml:"max_read_size"` // Larger files must be read in ranges (-1 = no limit)
+		MaxUpload   int64 `yaml:"max_upload"`    // Max bytes per upload request (-1 = no limit)
+		MaxExtract  int64 `yaml:"max_extract"`   // Max bytes unpacked from one archive (-1 = no limit)
+
+		ShowHidden bool     `yaml:"show_hidden"` // Show dotfiles in listings, search and the file tree
+		Exclude    []string `yaml:"exclude"`     // gitignore-style patterns hidden everywhere
+		Gitignore  bool     `yaml:"gitignore"`   // Also hide what .gitignore and .ignore files exclude
+
+		History struct {
+			Enabled     *bool `yaml:"enabled"`       // Pointer to distinguish unset from false
+			MaxVersions int   `yaml:"max_versions"`  // Snapshots kept per file
+			MaxDays     int   `yaml:"max_days"`      // Snapshots older than this are pruned
+			MaxFileSize int64 `yaml:"max_file_size"` // Larger files aren't snapshotted
+		} `yaml:"history"`
+
+		Trash struct {
+			Enabled *bool `yaml:"enabled"`  // Pointer to distinguish unset from false
+			MaxDays int   `yaml:"max_days"` // Items are purged after this many days (-1 = never)
+		} `yaml:"trash"`
+	} `yaml:"files"`
+
+	Search struct {
+		Index bool `yaml:"index"` // Keep a trigram index of each workspace for faster search
+	} `yaml:"search"`
+
+	Terminal struct {
+		Timeout     int   `yaml:"timeout"`      // Max seconds a command may run (-1 = no limit)
+		MaxOutput   int64 `yaml:"max_output"`   // Max bytes of output per command (-1 = no limit)
+		CPULimit    int   `yaml:"cpu_limit"`    // CPU seconds per command (0 = no limit)
+		MemoryLimit int   `yaml:"memory_limit"` // Virtual memory in MB per command (0 = no limit)
+	} `yaml:"terminal"`
+
 	Security struct {
 		AllowedIPs   []string `yaml:"allowed_ips"`
 		RequireHTTPS bool     `yaml:"require_https"`
 		LogIPs       *bool    `yaml:"log_ips"` // Pointer to distinguish unset from false
+
+		FollowExternalSymlinks bool `yaml:"follow_external_symlinks"` // Allow symlinks leading outside the base path
 	} `yaml:"security"`
 }
 
+// Workspace is a project root served by this instance
+type Workspace struct {
+	ID      string `yaml:"id"`       // Used in the API; defaults to the name
+	Name    string `yaml:"name"`     // Defaults to the directory name
+	Path    string `yaml:"path"`     // Project root
+	DataDir string `yaml:"data_dir"` // Backups of its files; defaults to .c00d in path
+
+	// Overrides of the files settings of the same name
+	ShowHidden *bool    `yaml:"show_hidden"`
+	Exclude    []string `yaml:"exclude"`
+	Gitignore  *bool    `yaml:"gitignore"`
+}
+
 // Global config instance
 var C Config
 
@@ -53,12 +105,19 @@ func ApplyDefaults() {
 	if C.Port == 0 {
 		C.Port = 3000
 	}
+	// The first workspace is the default one unless base_path is set
+	if C.BasePath == "" && len(C.Workspaces) > 0 {
+		C.BasePath = C.Workspaces[0].Path
+	}
 	if C.BasePath == "" {
 		C.BasePath, _ = os.Getwd()
 	}
+	// Path checks compare against clean absolute paths
+	C.BasePath, _ = filepath.Abs(C.BasePath)
 	if C.DataDir == "" {
 		C.DataDir = filepath.Join(C.BasePath, ".c00d")
 	}
+	C.DataDir, _ = filepath.Abs(C.DataDir)
 	if C.AI.Model == "" {
 		C.AI.Model = "claude-sonnet-4-20250514"
 	}
@@ -74,6 +133,46 @@ func ApplyDefaults() {
 	if C.Editor.Theme == "" {
 		C.Editor.Theme = "vs-dark"
 	}
+	if C.Files.Exclude == nil {
+		C.Files.Exclude = []string{".git", "node_modules", "vendor"}
+	}
+	if C.Files.MaxReadSize == 0 {
+		C.Files.MaxReadSize = 10 * 1024 * 1024
+	}
+	if C.Files.MaxUpload == 0 {
+		C.Files.MaxUpload = 100 * 1024 * 1024
+	}
+	if C.Files.MaxExtract == 0 {
+		C.Files.MaxExtract = 1024 * 1024 * 1024
+	}
+	// Default local history to on if not set
+	if C.Files.History.Enabled == nil {
+		defaultTrue := true
+		C.Files.History.Enabled = &defaultTrue
+	}
+	if C.Files.History.MaxVersions == 0 {
+		C.Files.History.MaxVersions = 100
+	}
+	if C.Files.History.MaxDays == 0 {
+		C.Files.History.MaxDays = 30
+	}
+	if C.Files.History.MaxFileSize == 0 {
+		C.Files.History.MaxFileSize = 5 * 1024 * 1024
+	}
+	// Default trash to on if not set
+	if C.Files.Trash.Enabled == nil {
+		defaultTrue := true
+		C.Files.Trash.Enabled = &defaultTrue
+	}
+	if C.Files.Trash.MaxDays == 0 {
+		C.Files.Trash.MaxDays = 30
+	}
+	if C.Terminal.Timeout == 0 {
+		C.Terminal.Timeout = 600
+	}
+	if C.Terminal.MaxOutput == 0 {
+		C.Terminal.MaxOutput = 10 * 1024 * 1024
+	}
 	// Default LogIPs to true if not set
 	if C.Security.LogIPs == nil {
 		defaultTrue := true
@@ -88,3 +187,19 @@ func ShouldLogIPs() bool {
 	}
 	return *C.Security.LogIPs
 }
+
+// ShouldKeepHistory returns whether local file history is enabled
+func ShouldKeepHistory() bool {
+	if C.Files.History.Enabled == nil {
+		return true
+	}
+	return *C.Files.History.Enabled
+}
+
+// ShouldUseTrash returns whether deletes move files to the trash
+func ShouldUseTrash() bool {
+	if C.Files.Trash.Enabled == nil {
+		return true
+	}
+	return *C.Files.Trash.Enabled
+}
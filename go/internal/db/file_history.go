@@ -0,0 +1,142 @@
+package db
+
+import "time"
+
+// FileSnapshot represents one entry in a file's local history. Path is
+// relative to the workspace Root.
+type FileSnapshot struct {
+	ID        int64  `json:"id"`
+	Root      string `json:"-"`
+	Path      string `json:"path"`
+	Event     string `json:"event"` // original, external, save, delete, rename, restore
+	Hash      string `json:"hash"`
+	Size      int64  `json:"size"`
+	OldPath   string `json:"old_path,omitempty"`
+	CreatedAt string `json:"created_at"`
+}
+
+const fileSnapshotColumns = "id, root, path, event, COALESCE(hash, ''), size, COALESCE(old_path, ''), created_at"
+
+func scanFileSnapshot(scan func(...any) error) (FileSnapshot, error) {
+	var s FileSnapshot
+	err := scan(&s.ID, &s.Root, &s.Path, &s.Event, &s.Hash, &s.Size, &s.OldPath, &s.CreatedAt)
+	return s, err
+}
+
+// AddFileSnapshot records a history entry for path under root
+func AddFileSnapshot(root, path, event, hash string, size int64, oldPath string) error {
+	_, err := DB.Exec(
+		"INSERT INTO file_history (root, path, event, hash, size, old_path, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		root, path, event, hash, size, oldPath, time.Now(),
+	)
+	return err
+}
+
+// GetFileSnapshot retrieves a single history entry
+func GetFileSnapshot(id int64) (*FileSnapshot, error) {
+	row := DB.QueryRow("SELECT "+fileSnapshotColumns+" FROM file_history WHERE id = ?", id)
+	s, err := scanFileSnapshot(row.Scan)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// LatestFileSnapshot retrieves the newest entry with content for path, or nil
+func LatestFileSnapshot(root, path string) *FileSnapshot {
+	row := DB.QueryRow("SELECT "+fileSnapshotColumns+
+		" FROM file_history WHERE root = ? AND path = ? AND hash != '' ORDER BY id DESC LIMIT 1", root, path)
+	s, err := scanFileSnapshot(row.Scan)
+	if err != nil {
+		return nil
+	}
+	return &s
+}
+
+// GetFileTimeline retrieves the history of path, newest first
+func GetFileTimeline(root, path string, limit int) ([]FileSnapshot, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	return queryFileSnapshots("SELECT "+fileSnapshotColumns+
+		" FROM file_history WHERE root = ? AND path = ? ORDER BY id DESC LIMIT ?", root, path, limit)
+}
+
+// GetDeletedFiles retrieves the last entry of every file under dir whose
+// most recent event is a delete, newest first
+func GetDeletedFiles(root, dir string, limit int) ([]FileSnapshot, error) {
+	if limit <= 0 {
+		limit = 100
+	}
+	query := "SELECT " + fileSnapshotColumns + ` FROM file_history
+		WHERE id IN (SELECT MAX(id) FROM file_history WHERE root = ? GROUP BY path) AND event = 'delete'`
+	args := []any{root}
+	if dir != "" && dir != "." {
+		query += ` AND path LIKE ? ESCAPE '\'`
+		args = append(args, escapeLike(dir)+"/%")
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, limit)
+	return queryFileSnapshots(query, args...)
+}
+
+// RenameFileHistory moves the history of oldPath, and of everything under
+// it when it was a directory, to newPath
+func RenameFileHistory(root, oldPath, newPath string) {
+	DB.Exec("UPDATE file_history SET path = ? WHERE root = ? AND path = ?", newPath, root, oldPath)
+	DB.Exec(`UPDATE file_history SET path = ? || substr(path, ?) WHERE root = ? AND path LIKE ? ESCAPE '\'`,
+		newPath, len(oldPath)+1, root, escapeLike(oldPath)+"/%")
+}
+
+// PruneFileHistory removes entries older than maxDays and all but the
+// newest maxVersions entries of each file
+func PruneFileHistory(maxVersions, maxDays int) {
+	if maxDays > 0 {
+		DB.Exec("DELETE FROM file_history WHERE created_at < ?", time.Now().AddDate(0, 0, -maxDays))
+	}
+	if maxVersions > 0 {
+		DB.Exec(`
+			DELETE FROM file_history WHERE id IN (
+				SELECT id FROM (
+					SELECT id, ROW_NUMBER() OVER (PARTITION BY root, path ORDER BY id DESC) AS n
+					FROM file_history
+				) WHERE n > ?
+			)`, maxVersions)
+	}
+}
+
+// FileHistoryHashes returns every content hash still referenced by history
+func FileHistoryHashes() (map[string]bool, error) {
+	rows, err := DB.Query("SELECT DISTINCT hash FROM file_history WHERE hash != ''")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := map[string]bool{}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err == nil {
+			hashes[hash] = true
+		}
+	}
+	return hashes, rows.Err()
+}
+
+func queryFileSnapshots(query string, args ...any) ([]FileSnapshot, error) {
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []FileSnapshot{}
+	for rows.Next() {
+		s, err := scanFileSnapshot(rows.Scan)
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, nil
+}
@@ -0,0 +1,119 @@
+package db
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// CommandHistoryEntry represents a command run through the terminal API
+type CommandHistoryEntry struct {
+	ID         int64  `json:"id"`
+	Root       string `json:"-"` // Workspace the command ran in
+	Command    string `json:"command"`
+	Cwd        string `json:"cwd"` // Relative to Root, "." for the root itself
+	ExitCode   *int   `json:"exit_code"`
+	DurationMs *int64 `json:"duration_ms"`
+	CreatedAt  string `json:"created_at"`
+}
+
+// HistoryQuery filters command history
+type HistoryQuery struct {
+	Search string         // Case-insensitive substring
+	Regex  *regexp.Regexp // Applied after Search
+	Root   string         // Only commands run in this workspace
+	Cwd    string         // Only commands run in this directory, relative to Root
+	Unique bool           // Only the most recent run of each command
+	Limit  int
+	Offset int
+}
+
+// AddCommandHistory records a command run in cwd, relative to the workspace
+// root, as it starts and returns its ID
+func AddCommandHistory(root, command, cwd string) int64 {
+	result, err := DB.Exec("INSERT INTO command_history (root, command, cwd) VALUES (?, ?, ?)", root, command, cwd)
+	if err != nil {
+		return 0
+	}
+	id, _ := result.LastInsertId()
+	return id
+}
+
+// FinishCommandHistory records how a command ended
+func FinishCommandHistory(id int64, exitCode int, duration time.Duration) {
+	DB.Exec("UPDATE command_history SET exit_code = ?, duration_ms = ? WHERE id = ?",
+		exitCode, duration.Milliseconds(), id)
+}
+
+// GetCommandHistory retrieves matching commands, newest first. It returns
+// one more entry than the limit when more results are available.
+func GetCommandHistory(q HistoryQuery) ([]CommandHistoryEntry, error) {
+	if q.Limit <= 0 {
+		q.Limit = 50
+	}
+
+	where := []string{"1 = 1"}
+	args := []any{}
+	if q.Search != "" {
+		where = append(where, `command LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(q.Search)+"%")
+	}
+	if q.Root != "" {
+		where = append(where, "root = ?")
+		args = append(args, q.Root)
+	}
+	if q.Cwd != "" {
+		where = append(where, "cwd = ?")
+		args = append(args, q.Cwd)
+	}
+	filter := strings.Join(where, " AND ")
+	if q.Unique {
+		filter = "id IN (SELECT MAX(id) FROM command_history WHERE " + filter + " GROUP BY command)"
+	}
+
+	query := `
+		SELECT id, root, command, COALESCE(cwd, ''), exit_code, duration_ms, created_at
+		FROM command_history
+		WHERE ` + filter + `
+		ORDER BY id DESC`
+
+	// Regex filtering happens here, so pagination has to as well
+	if q.Regex == nil {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, q.Limit+1, q.Offset)
+	}
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []CommandHistoryEntry{}
+	skipped := 0
+	for rows.Next() {
+		var e CommandHistoryEntry
+		if err := rows.Scan(&e.ID, &e.Root, &e.Command, &e.Cwd, &e.ExitCode, &e.DurationMs, &e.CreatedAt); err != nil {
+			continue
+		}
+		if q.Regex != nil {
+			if !q.Regex.MatchString(e.Command) {
+				continue
+			}
+			if skipped < q.Offset {
+				skipped++
+				continue
+			}
+		}
+		entries = append(entries, e)
+		if len(entries) > q.Limit {
+			break
+		}
+	}
+	return entries, nil
+}
+
+// escapeLike escapes LIKE wildcards so s matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
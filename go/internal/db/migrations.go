@@ -1,5 +1,7 @@
 package db
 
+import "github.com/c00d-ide/c00d/internal/config"
+
 // RunMigrations creates all necessary database tables
 func RunMigrations() {
 	DB.Exec(`
@@ -9,9 +11,48 @@ func RunMigrations() {
 		);
 		CREATE TABLE IF NOT EXISTS command_history (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			root TEXT NOT NULL DEFAULT '',
 			command TEXT,
+			cwd TEXT,
+			exit_code INTEGER,
+			duration_ms INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE TABLE IF NOT EXISTS terminal_sessions (
+			id TEXT PRIMARY KEY,
+			name TEXT,
+			cwd TEXT,
+			pid INTEGER,
+			status TEXT NOT NULL,
+			exit_code INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			last_attached DATETIME,
+			closed_at DATETIME
+		);
+		CREATE INDEX IF NOT EXISTS idx_terminal_sessions_status ON terminal_sessions(status);
+		CREATE TABLE IF NOT EXISTS file_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			root TEXT NOT NULL DEFAULT '',
+			path TEXT NOT NULL,
+			event TEXT NOT NULL,
+			hash TEXT,
+			size INTEGER DEFAULT 0,
+			old_path TEXT,
 			created_at DATETIME DEFAULT CURRENT_TIMESTAMP
 		);
+		CREATE TABLE IF NOT EXISTS trash (
+			id TEXT PRIMARY KEY,
+			root TEXT NOT NULL DEFAULT '',
+			original_path TEXT NOT NULL,
+			is_dir INTEGER DEFAULT 0,
+			size INTEGER DEFAULT 0,
+			deleted_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
+		CREATE INDEX IF NOT EXISTS idx_trash_deleted ON trash(deleted_at);
+		CREATE TABLE IF NOT EXISTS recent_files (
+			file_path TEXT PRIMARY KEY,
+			accessed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+		);
 		CREATE TABLE IF NOT EXISTS ai_history (
 			id INTEGER PRIMARY KEY AUTOINCREMENT,
 			role TEXT,
@@ -43,4 +84,21 @@ func RunMigrations() {
 		CREATE INDEX IF NOT EXISTS idx_ip_logs_ip ON ip_logs(ip_address);
 		CREATE INDEX IF NOT EXISTS idx_ip_logs_created ON ip_logs(created_at);
 	`)
+
+	// Columns added to existing tables; these fail harmlessly once present
+	DB.Exec("ALTER TABLE command_history ADD COLUMN cwd TEXT")
+	DB.Exec("ALTER TABLE command_history ADD COLUMN exit_code INTEGER")
+	DB.Exec("ALTER TABLE command_history ADD COLUMN duration_ms INTEGER")
+	DB.Exec("ALTER TABLE command_history ADD COLUMN root TEXT NOT NULL DEFAULT ''")
+	DB.Exec("DROP INDEX IF EXISTS idx_command_history_cwd")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_command_history_root_cwd ON command_history(root, cwd)")
+	DB.Exec("ALTER TABLE file_history ADD COLUMN root TEXT NOT NULL DEFAULT ''")
+	DB.Exec("ALTER TABLE trash ADD COLUMN root TEXT NOT NULL DEFAULT ''")
+	DB.Exec("DROP INDEX IF EXISTS idx_file_history_path")
+	DB.Exec("CREATE INDEX IF NOT EXISTS idx_file_history_root_path ON file_history(root, path)")
+
+	// Paths recorded before workspaces existed belong to the base path
+	DB.Exec("UPDATE file_history SET root = ? WHERE root = ''", config.C.BasePath)
+	DB.Exec("UPDATE trash SET root = ? WHERE root = ''", config.C.BasePath)
+	DB.Exec("UPDATE command_history SET root = ? WHERE root = ''", config.C.BasePath)
 }
@@ -0,0 +1,35 @@
+package db
+
+import "time"
+
+// recentFilesKept is how many recently opened files are remembered
+const recentFilesKept = 50
+
+// AddRecentFile records that a file was opened. Paths are absolute, so
+// files of different workspaces are told apart.
+func AddRecentFile(path string) {
+	DB.Exec("INSERT OR REPLACE INTO recent_files (file_path, accessed_at) VALUES (?, ?)", path, time.Now())
+	DB.Exec(`DELETE FROM recent_files WHERE file_path NOT IN (
+		SELECT file_path FROM recent_files ORDER BY accessed_at DESC LIMIT ?)`, recentFilesKept)
+}
+
+// GetRecentFiles retrieves recently opened files, most recent first
+func GetRecentFiles(limit int) []string {
+	if limit <= 0 {
+		limit = 20
+	}
+	rows, err := DB.Query("SELECT file_path FROM recent_files ORDER BY accessed_at DESC LIMIT ?", limit)
+	if err != nil {
+		return []string{}
+	}
+	defer rows.Close()
+
+	files := []string{}
+	for rows.Next() {
+		var path string
+		if err := rows.Scan(&path); err == nil {
+			files = append(files, path)
+		}
+	}
+	return files
+}
@@ -0,0 +1,81 @@
+package db
+
+import (
+	"database/sql"
+	"time"
+)
+
+// TerminalSession represents a stored terminal session
+type TerminalSession struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Cwd          string `json:"cwd"`
+	PID          int    `json:"pid"`
+	Status       string `json:"status"` // running, exited, killed, lost
+	ExitCode     *int   `json:"exit_code,omitempty"`
+	CreatedAt    string `json:"created_at"`
+	LastAttached string `json:"last_attached,omitempty"`
+	ClosedAt     string `json:"closed_at,omitempty"`
+}
+
+// CreateTerminalSession records a newly started terminal session
+func CreateTerminalSession(id, name, cwd string, pid int) error {
+	_, err := DB.Exec(
+		"INSERT INTO terminal_sessions (id, name, cwd, pid, status, last_attached) VALUES (?, ?, ?, ?, 'running', ?)",
+		id, name, cwd, pid, time.Now(),
+	)
+	return err
+}
+
+// TouchTerminalSession updates the last attached time of a session
+func TouchTerminalSession(id string) {
+	DB.Exec("UPDATE terminal_sessions SET last_attached = ? WHERE id = ?", time.Now(), id)
+}
+
+// CloseTerminalSession marks a session as finished
+func CloseTerminalSession(id, status string, exitCode int) {
+	DB.Exec(
+		"UPDATE terminal_sessions SET status = ?, exit_code = ?, closed_at = ? WHERE id = ?",
+		status, exitCode, time.Now(), id,
+	)
+}
+
+// MarkLostTerminalSessions marks sessions left running by a previous server
+// process, whose shells died with it
+func MarkLostTerminalSessions() {
+	DB.Exec("UPDATE terminal_sessions SET status = 'lost', closed_at = ? WHERE status = 'running'", time.Now())
+}
+
+// GetTerminalSessions retrieves stored sessions, newest first
+func GetTerminalSessions(includeClosed bool, limit int) ([]TerminalSession, error) {
+	if limit <= 0 {
+		limit = 50
+	}
+
+	query := `
+		SELECT id, name, cwd, pid, status, exit_code, created_at, last_attached, closed_at
+		FROM terminal_sessions`
+	if !includeClosed {
+		query += " WHERE status = 'running'"
+	}
+	query += " ORDER BY created_at DESC LIMIT ?"
+
+	rows, err := DB.Query(query, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []TerminalSession{}
+	for rows.Next() {
+		var s TerminalSession
+		var lastAttached, closedAt sql.NullString
+		if err := rows.Scan(&s.ID, &s.Name, &s.Cwd, &s.PID, &s.Status, &s.ExitCode, &s.CreatedAt, &lastAttached, &closedAt); err != nil {
+			continue
+		}
+		s.LastAttached = lastAttached.String
+		s.ClosedAt = closedAt.String
+		sessions = append(sessions, s)
+	}
+	return sessions, nil
+}
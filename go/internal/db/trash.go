@@ -0,0 +1,73 @@
+package db
+
+import "time"
+
+// TrashItem represents a deleted file or directory held in the trash.
+// OriginalPath is relative to the workspace Root.
+type TrashItem struct {
+	ID           string `json:"id"`
+	Root         string `json:"-"`
+	OriginalPath string `json:"original_path"`
+	IsDir        bool   `json:"is_dir"`
+	Size         int64  `json:"size"`
+	DeletedAt    string `json:"deleted_at"`
+}
+
+// AddTrashItem records an item moved into the trash
+func AddTrashItem(id, root, originalPath string, isDir bool, size int64) error {
+	_, err := DB.Exec(
+		"INSERT INTO trash (id, root, original_path, is_dir, size, deleted_at) VALUES (?, ?, ?, ?, ?, ?)",
+		id, root, originalPath, isDir, size, time.Now(),
+	)
+	return err
+}
+
+// GetTrashItem retrieves a single trash item
+func GetTrashItem(id string) (*TrashItem, error) {
+	var item TrashItem
+	err := DB.QueryRow(
+		"SELECT id, root, original_path, is_dir, size, deleted_at FROM trash WHERE id = ?", id,
+	).Scan(&item.ID, &item.Root, &item.OriginalPath, &item.IsDir, &item.Size, &item.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &item, nil
+}
+
+// GetTrashItems retrieves items in the trash, newest first. A non-empty
+// root only returns items deleted from it, and a non-zero olderThan only
+// items deleted before it.
+func GetTrashItems(root string, olderThan time.Time) ([]TrashItem, error) {
+	query := "SELECT id, root, original_path, is_dir, size, deleted_at FROM trash WHERE 1 = 1"
+	args := []any{}
+	if root != "" {
+		query += " AND root = ?"
+		args = append(args, root)
+	}
+	if !olderThan.IsZero() {
+		query += " AND deleted_at < ?"
+		args = append(args, olderThan)
+	}
+	query += " ORDER BY deleted_at DESC"
+
+	rows, err := DB.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []TrashItem{}
+	for rows.Next() {
+		var item TrashItem
+		if err := rows.Scan(&item.ID, &item.Root, &item.OriginalPath, &item.IsDir, &item.Size, &item.DeletedAt); err != nil {
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// DeleteTrashItem removes the record of a trash item
+func DeleteTrashItem(id string) {
+	DB.Exec("DELETE FROM trash WHERE id = ?", id)
+}
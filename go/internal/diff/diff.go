@@ -0,0 +1,197 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Edit kinds
+const (
+	Equal  = ' '
+	Delete = '-'
+	Insert = '+'
+)
+
+// Edit is one line of an edit script
+type Edit struct {
+	Kind byte
+	A    int // Line index in a (Equal, Delete)
+	B    int // Line index in b (Equal, Insert)
+	Line string
+}
+
+// Lines splits text into lines, each keeping its trailing newline
+func Lines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Edits returns a shortest edit script turning a into b, using Myers' algorithm
+func Edits(a, b []string) []Edit {
+	n, m := len(a), len(b)
+	max := n + m
+	if max == 0 {
+		return nil
+	}
+
+	// v[k] is the furthest x reached on diagonal k; trace keeps the part of
+	// v each round of d started from, for walking back the path
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		done := false
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				done = true
+				break
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	// Walk back from (n, m), collecting edits in reverse
+	var edits []Edit
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		tv := trace[d]
+		at := func(k int) int { return tv[k+d+1] }
+
+		k := x - y
+		var prevK int
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := at(prevK)
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			edits = append(edits, Edit{Kind: Equal, A: x, B: y, Line: a[x]})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				edits = append(edits, Edit{Kind: Insert, A: x, B: y, Line: b[y]})
+			} else {
+				x--
+				edits = append(edits, Edit{Kind: Delete, A: x, B: y, Line: a[x]})
+			}
+		}
+	}
+
+	for i, j := 0, len(edits)-1; i < j; i, j = i+1, j-1 {
+		edits[i], edits[j] = edits[j], edits[i]
+	}
+	return edits
+}
+
+// Unified returns a unified diff between texts a and b with the given
+// number of context lines, or "" if they are equal
+func Unified(aName, bName, a, b string, context int) string {
+	edits := Edits(Lines(a), Lines(b))
+
+	var out strings.Builder
+	for _, h := range hunks(edits, context) {
+		if out.Len() == 0 {
+			fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+		}
+		out.WriteString(h)
+	}
+	return out.String()
+}
+
+// hunks groups edits into unified diff hunks
+func hunks(edits []Edit, context int) []string {
+	var result []string
+
+	for i := 0; i < len(edits); {
+		// Find the next change
+		for i < len(edits) && edits[i].Kind == Equal {
+			i++
+		}
+		if i == len(edits) {
+			break
+		}
+
+		start := max(i-context, 0)
+		end := i
+		// Extend while changes are within 2*context lines of each other
+		for end < len(edits) {
+			if edits[end].Kind != Equal {
+				end++
+				continue
+			}
+			run := end
+			for run < len(edits) && edits[run].Kind == Equal {
+				run++
+			}
+			if run == len(edits) || run-end > 2*context {
+				end = min(end+context, len(edits))
+				break
+			}
+			end = run
+		}
+
+		result = append(result, formatHunk(edits[start:end]))
+		i = end
+	}
+	return result
+}
+
+func formatHunk(edits []Edit) string {
+	aStart, bStart := edits[0].A, edits[0].B
+	aCount, bCount := 0, 0
+	var body strings.Builder
+	for _, e := range edits {
+		switch e.Kind {
+		case Equal:
+			aCount++
+			bCount++
+		case Delete:
+			aCount++
+		case Insert:
+			bCount++
+		}
+		body.WriteByte(e.Kind)
+		body.WriteString(e.Line)
+		if !strings.HasSuffix(e.Line, "\n") {
+			body.WriteString("\n\\ No newline at end of file\n")
+		}
+	}
+
+	// Unified diff line numbers are 1-based, and point at the line before
+	// an empty range
+	if aCount > 0 {
+		aStart++
+	}
+	if bCount > 0 {
+		bStart++
+	}
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@\n", aStart, aCount, bStart, bCount) + body.String()
+}
@@ -0,0 +1,284 @@
+package filetree
+
+import (
+	"bytes"
+	"io/fs"
+	"log"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c00d-ide/c00d/internal/ignore"
+	"github.com/c00d-ide/c00d/internal/watcher"
+)
+
+// Sources a tree can be built from
+const (
+	SourceGit  = "git"  // git ls-files
+	SourceWalk = "walk" // Directory walk with the default skip rules
+)
+
+// Tree is a cached recursive listing of every file under a root, kept up
+// to date from watcher events
+type Tree struct {
+	Root string
+
+	mu      sync.RWMutex
+	files   map[string]struct{}
+	sorted  []string // Cached sorted copy of files, nil when stale
+	source  string
+	builtAt time.Time
+	ready   chan struct{}
+}
+
+var (
+	treesMu sync.Mutex
+	trees   = map[string]*Tree{}
+)
+
+// For returns the tree for root, building it on first use
+func For(root string) *Tree {
+	treesMu.Lock()
+	defer treesMu.Unlock()
+
+	if t, ok := trees[root]; ok {
+		return t
+	}
+
+	t := &Tree{Root: root, ready: make(chan struct{})}
+	go func() {
+		t.Rebuild()
+		close(t.ready)
+		t.follow()
+	}()
+
+	trees[root] = t
+	return t
+}
+
+// Files returns every file path under the root, relative and
+// slash-separated, in sorted order. It waits for the first build.
+func (t *Tree) Files() []string {
+	<-t.ready
+
+	t.mu.RLock()
+	sorted := t.sorted
+	t.mu.RUnlock()
+	if sorted != nil {
+		return sorted
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.sorted == nil {
+		t.sorted = make([]string, 0, len(t.files))
+		for p := range t.files {
+			t.sorted = append(t.sorted, p)
+		}
+		sort.Strings(t.sorted)
+	}
+	return t.sorted
+}
+
+// Info returns how the tree was built and when
+func (t *Tree) Info() (source string, builtAt time.Time) {
+	<-t.ready
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.source, t.builtAt
+}
+
+// Rebuild lists the whole root again
+func (t *Tree) Rebuild() {
+	files, source := t.list("")
+
+	t.mu.Lock()
+	t.files = files
+	t.sorted = nil
+	t.source = source
+	t.builtAt = time.Now()
+	t.mu.Unlock()
+}
+
+// list returns the files under dir (relative to the root, "" for all of
+// it), using git when the root is in a repository
+func (t *Tree) list(dir string) (map[string]struct{}, string) {
+	if files, err := t.gitFiles(dir); err == nil {
+		return files, SourceGit
+	}
+	return t.walkFiles(dir), SourceWalk
+}
+
+// gitFiles lists tracked and untracked files under the given paths,
+// leaving out tracked files deleted from disk. git applies .gitignore
+// itself when files.gitignore is on.
+func (t *Tree) gitFiles(paths ...string) (map[string]struct{}, error) {
+	args := []string{"--cached", "--others"}
+	if ignore.For(t.Root).Gitignore() {
+		args = append(args, "--exclude-standard")
+	}
+	names, err := t.lsFiles(args, paths)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := t.lsFiles([]string{"--deleted"}, paths)
+	if err != nil {
+		return nil, err
+	}
+	gone := make(map[string]bool, len(deleted))
+	for _, name := range deleted {
+		gone[name] = true
+	}
+
+	// git knows .gitignore, but not the data directory, dotfiles or files.exclude
+	files := map[string]struct{}{}
+	for _, name := range ignore.For(t.Root).Filter(names) {
+		if !gone[name] {
+			files[name] = struct{}{}
+		}
+	}
+	return files, nil
+}
+
+// lsFiles runs git ls-files with args over paths ("" for the root)
+func (t *Tree) lsFiles(args, paths []string) ([]string, error) {
+	args = append([]string{"ls-files", "-z"}, args...)
+	args = append(args, "--")
+	for _, p := range paths {
+		if p == "" {
+			p = "."
+		}
+		args = append(args, p)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Dir = t.Root
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, name := range bytes.Split(out, []byte{0}) {
+		if len(name) > 0 {
+			names = append(names, string(name))
+		}
+	}
+	return names, nil
+}
+
+// walkFiles lists files under dir, leaving out hidden and excluded ones
+func (t *Tree) walkFiles(dir string) map[string]struct{} {
+	files := map[string]struct{}{}
+	matcher := ignore.For(t.Root)
+	start := filepath.Join(t.Root, filepath.FromSlash(dir))
+	if dir != "" && matcher.Ignored(dir, true) {
+		return files
+	}
+	filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if p != start && matcher.MatchPath(p, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() {
+			rel, _ := filepath.Rel(t.Root, p)
+			files[filepath.ToSlash(rel)] = struct{}{}
+		}
+		return nil
+	})
+	return files
+}
+
+// follow applies watcher events to the tree until the process exits
+func (t *Tree) follow() {
+	events, _ := watcher.For(t.Root).Subscribe()
+	for batch := range events {
+		t.apply(batch)
+	}
+}
+
+// apply updates the tree for a batch of events. Removed paths are dropped
+// along with anything under them; created paths are listed again so
+// ignore rules and new directories' contents are picked up.
+func (t *Tree) apply(batch []watcher.Event) {
+	var removed, added []string
+	for _, ev := range batch {
+		switch ev.Type {
+		case watcher.Overflow:
+			t.Rebuild()
+			return
+		case watcher.Delete:
+			removed = append(removed, ev.Path)
+		case watcher.Rename:
+			removed = append(removed, ev.OldPath)
+			added = append(added, ev.Path)
+		case watcher.Create:
+			added = append(added, ev.Path)
+		}
+	}
+	if len(removed) == 0 && len(added) == 0 {
+		return
+	}
+
+	found := map[string]struct{}{}
+	if len(added) > 0 {
+		t.mu.RLock()
+		source := t.source
+		t.mu.RUnlock()
+
+		if source == SourceGit {
+			files, err := t.gitFiles(added...)
+			if err != nil {
+				log.Printf("filetree: git ls-files failed (%v), rebuilding", err)
+				t.Rebuild()
+				return
+			}
+			found = files
+		} else {
+			for _, p := range added {
+				for f := range t.walkFiles(p) {
+					found[f] = struct{}{}
+				}
+			}
+		}
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, p := range removed {
+		delete(t.files, p)
+		prefix := p + "/"
+		for f := range t.files {
+			if strings.HasPrefix(f, prefix) {
+				delete(t.files, f)
+			}
+		}
+	}
+	for f := range found {
+		t.files[f] = struct{}{}
+	}
+	t.sorted = nil
+}
+
+// Under returns the files in dir and below, relative to the root
+func Under(files []string, dir string) []string {
+	dir = strings.Trim(path.Clean("/"+dir), "/")
+	if dir == "" {
+		return files
+	}
+	prefix := dir + "/"
+	start := sort.SearchStrings(files, prefix)
+	end := start
+	for end < len(files) && strings.HasPrefix(files[end], prefix) {
+		end++
+	}
+	return files[start:end]
+}
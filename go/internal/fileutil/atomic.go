@@ -0,0 +1,69 @@
+package fileutil
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// WriteFileAtomic replaces the file at path with data without ever leaving
+// a partially written file behind. The data goes to a temp file in the same
+// directory, which is synced and then renamed over the target. An existing
+// file keeps its mode and ownership; a new one is created with perm.
+// Symlinks are followed, so the link's target is replaced, not the link.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	_, err := WriteReaderAtomic(path, bytes.NewReader(data), perm)
+	return err
+}
+
+// WriteReaderAtomic is WriteFileAtomic for content streamed from r. It
+// returns the number of bytes written.
+func WriteReaderAtomic(path string, r io.Reader, perm os.FileMode) (int64, error) {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+
+	existing, err := os.Stat(path)
+	if err == nil {
+		perm = existing.Mode().Perm()
+	} else if !os.IsNotExist(err) {
+		return 0, err
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".c00d-*.tmp")
+	if err != nil {
+		return 0, err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // No-op once renamed
+
+	n, err := io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return 0, err
+	}
+	if existing != nil {
+		copyOwner(tmpPath, existing)
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return 0, err
+	}
+
+	// Make the rename itself durable
+	syncDir(dir)
+	return n, nil
+}
@@ -0,0 +1,65 @@
+package fileutil
+
+import (
+	"mime"
+	"net/http"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+// sniffLen is how much of a file is inspected to tell text from binary
+const sniffLen = 8192
+
+// IsBinary reports whether content looks like binary data rather than
+// text: it has control bytes other than tab, CR, LF, form feed and escape
+// (as in ANSI-coloured logs), or isn't valid UTF-8. Only the first 8 KB are
+// inspected.
+func IsBinary(content []byte) bool {
+	sniff := content
+	if len(sniff) > sniffLen {
+		sniff = sniff[:sniffLen]
+		// Drop a multi-byte rune cut off by the limit
+		for i := 1; i < utf8.UTFMax; i++ {
+			if utf8.RuneStart(sniff[len(sniff)-i]) {
+				if !utf8.FullRune(sniff[len(sniff)-i:]) {
+					sniff = sniff[:len(sniff)-i]
+				}
+				break
+			}
+		}
+	}
+	for _, b := range sniff {
+		if b < 0x20 && b != '\t' && b != '\n' && b != '\r' && b != '\f' && b != 0x1b {
+			return true
+		}
+	}
+	return !utf8.Valid(sniff)
+}
+
+// DetectMIME returns the MIME type of a file from its extension, falling
+// back to sniffing its content
+func DetectMIME(name string, content []byte) string {
+	if t := mime.TypeByExtension(filepath.Ext(name)); t != "" {
+		return t
+	}
+	if len(content) > 512 {
+		content = content[:512]
+	}
+	return http.DetectContentType(content)
+}
+
+// SplitUTF8 splits b into a valid UTF-8 prefix and a trailing incomplete
+// rune, so multi-byte characters split across reads aren't mangled
+func SplitUTF8(b []byte) ([]byte, []byte) {
+	for i := 1; i <= utf8.UTFMax-1 && i <= len(b); i++ {
+		start := len(b) - i
+		if !utf8.RuneStart(b[start]) {
+			continue
+		}
+		if !utf8.FullRune(b[start:]) {
+			return b[:start], append([]byte(nil), b[start:]...)
+		}
+		break
+	}
+	return b, nil
+}
@@ -0,0 +1,26 @@
+package fileutil
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestIsBinary(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		binary  bool
+	}{
+		{"text", []byte("hello\r\n\tworld\n"), false},
+		{"ansi colours", []byte("\x1b[31merror\x1b[0m\n"), false},
+		{"form feed", []byte("page one\n\fpage two\n"), false},
+		{"nul", []byte("a\x00b"), true},
+		{"latin-1", []byte("caf\xe9"), true},
+		{"utf-8 cut at the limit", append(bytes.Repeat([]byte("a"), sniffLen-1), "é"...), false},
+	}
+	for _, tt := range tests {
+		if got := IsBinary(tt.content); got != tt.binary {
+			t.Errorf("%s: IsBinary = %v, want %v", tt.name, got, tt.binary)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+//go:build !windows
+
+package fileutil
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+// copyOwner gives path the same owner and group as info. It fails silently
+// when the server isn't allowed to chown, leaving the server's own user.
+func copyOwner(path string, info os.FileInfo) {
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		os.Lchown(path, int(stat.Uid), int(stat.Gid))
+	}
+}
+
+// syncDir flushes a directory entry to disk
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+// isCrossDevice reports whether a rename failed because source and target
+// are on different filesystems
+func isCrossDevice(err error) bool {
+	return errors.Is(err, syscall.EXDEV)
+}
+
+var (
+	namesMu sync.Mutex
+	names   = map[string]string{} // "u<uid>" and "g<gid>" -> name
+)
+
+// Owner returns the user and group names owning a file, or their numeric
+// ids when they have no name
+func Owner(info os.FileInfo) (string, string) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", ""
+	}
+	uid := strconv.FormatUint(uint64(stat.Uid), 10)
+	gid := strconv.FormatUint(uint64(stat.Gid), 10)
+	return lookupName("u", uid), lookupName("g", gid)
+}
+
+// lookupName resolves a user ("u") or group ("g") id, caching the result
+// since every lookup reads /etc/passwd or /etc/group
+func lookupName(kind, id string) string {
+	namesMu.Lock()
+	defer namesMu.Unlock()
+
+	if name, ok := names[kind+id]; ok {
+		return name
+	}
+	name := id
+	if kind == "u" {
+		if u, err := user.LookupId(id); err == nil {
+			name = u.Username
+		}
+	} else if g, err := user.LookupGroupId(id); err == nil {
+		name = g.Name
+	}
+	names[kind+id] = name
+	return name
+}
@@ -0,0 +1,30 @@
+//go:build windows
+
+package fileutil
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// copyOwner is a no-op on Windows, where new files inherit ACLs from their directory
+func copyOwner(path string, info os.FileInfo) {}
+
+// syncDir is a no-op on Windows, which can't fsync directories
+func syncDir(dir string) {}
+
+// errNotSameDevice is ERROR_NOT_SAME_DEVICE, returned when moving a file
+// across volumes
+const errNotSameDevice = syscall.Errno(17)
+
+// isCrossDevice reports whether a rename failed because source and target
+// are on different volumes
+func isCrossDevice(err error) bool {
+	return errors.Is(err, errNotSameDevice)
+}
+
+// Owner returns no owner on Windows, where files are owned through ACLs
+func Owner(info os.FileInfo) (string, string) {
+	return "", ""
+}
@@ -0,0 +1,19 @@
+package fileutil
+
+import "path/filepath"
+
+// RealPath resolves the symlinks in the part of fullPath that exists,
+// keeping the rest as it is
+func RealPath(fullPath string) string {
+	fullPath = filepath.Clean(fullPath)
+	rest := ""
+	for dir := fullPath; ; dir = filepath.Dir(dir) {
+		if real, err := filepath.EvalSymlinks(dir); err == nil {
+			return filepath.Join(real, rest)
+		}
+		if dir == filepath.Dir(dir) {
+			return fullPath
+		}
+		rest = filepath.Join(filepath.Base(dir), rest)
+	}
+}
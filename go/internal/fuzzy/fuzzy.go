@@ -0,0 +1,192 @@
+package fuzzy
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// Scoring weights. Matches are rewarded, more so at the start of a path
+// segment or word and when consecutive; gaps between matches cost a little.
+const (
+	scoreMatch       = 16
+	bonusBoundary    = 8 // After /, _, -, . or a space, or at the start
+	bonusCamel       = 7 // Upper case letter after a lower case one
+	bonusConsecutive = 12
+	bonusBasename    = 2 // Per matched character in the file name itself
+	penaltyGap       = 1 // Per skipped character between matches
+
+	penaltyDepth = 3  // Per directory level
+	bonusRecent  = 40 // For the most recently opened file, less for older ones
+
+	maxPattern   = 64
+	maxCandidate = 1024
+)
+
+const none = math.MinInt32 / 2
+
+// Match is a file path matched by a pattern
+type Match struct {
+	Path      string `json:"path"`
+	Score     int    `json:"score"`
+	Positions []int  `json:"positions"` // Byte offsets of matched characters
+}
+
+// Find ranks paths against pattern, case-insensitively, and returns the
+// best limit matches. Every pattern character must appear in order. Scores
+// favour matches in the file name, at word boundaries and in a run, shallow
+// paths, and files in recent (most recent first). An empty pattern lists
+// recent files, then the shallowest paths.
+func Find(pattern string, paths, recent []string, limit int) []Match {
+	pattern = strings.ToLower(strings.ReplaceAll(pattern, " ", ""))
+	if len(pattern) > maxPattern {
+		pattern = pattern[:maxPattern]
+	}
+
+	recentRank := make(map[string]int, len(recent))
+	for i, p := range recent {
+		if _, ok := recentRank[p]; !ok {
+			recentRank[p] = i
+		}
+	}
+
+	matches := []Match{}
+	for _, p := range paths {
+		var m Match
+		if pattern == "" {
+			m = Match{Path: p, Positions: []int{}}
+		} else {
+			score, positions, ok := Score(pattern, p)
+			if !ok {
+				continue
+			}
+			m = Match{Path: p, Score: score, Positions: positions}
+		}
+		m.Score -= strings.Count(p, "/") * penaltyDepth
+		if r, ok := recentRank[p]; ok {
+			m.Score += bonusRecent * (len(recent) - r) / len(recent)
+		}
+		matches = append(matches, m)
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Score != matches[j].Score {
+			return matches[i].Score > matches[j].Score
+		}
+		if len(matches[i].Path) != len(matches[j].Path) {
+			return len(matches[i].Path) < len(matches[j].Path)
+		}
+		return matches[i].Path < matches[j].Path
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches
+}
+
+// Score returns how well pattern, which must be lower case, matches
+// candidate as a subsequence, and the byte offsets of the best match
+func Score(pattern, candidate string) (int, []int, bool) {
+	m, n := len(pattern), len(candidate)
+	if m == 0 || n > maxCandidate || !isSubsequence(pattern, candidate) {
+		return 0, nil, m == 0
+	}
+
+	base := strings.LastIndexByte(candidate, '/') + 1
+	bonus := make([]int, n)
+	for j := 0; j < n; j++ {
+		bonus[j] = charBonus(candidate, j)
+		if j >= base {
+			bonus[j] += bonusBasename
+		}
+	}
+
+	// prev[j] is the best score with pattern[i-1] matched at candidate[j];
+	// from[i][j] is where pattern[i-1] was matched on that best path
+	prev := make([]int, n)
+	cur := make([]int, n)
+	from := make([][]int, m)
+	for i := 0; i < m; i++ {
+		from[i] = make([]int, n)
+		runScore, runFrom := none, -1 // Best earlier match at least one gap away
+		for j := 0; j < n; j++ {
+			if i > 0 {
+				if runFrom >= 0 {
+					runScore -= penaltyGap
+				}
+				if j >= 2 && prev[j-2] > none && prev[j-2]-penaltyGap > runScore {
+					runScore, runFrom = prev[j-2]-penaltyGap, j-2
+				}
+			}
+
+			cur[j] = none
+			if lower(candidate[j]) != pattern[i] {
+				continue
+			}
+			if i == 0 {
+				cur[j] = scoreMatch + bonus[j]
+				from[i][j] = -1
+				continue
+			}
+			best, bestFrom := none, -1
+			if j >= 1 && prev[j-1] > none {
+				best, bestFrom = prev[j-1]+bonusConsecutive, j-1
+			}
+			if runScore > best {
+				best, bestFrom = runScore, runFrom
+			}
+			if best > none {
+				cur[j] = best + scoreMatch + bonus[j]
+				from[i][j] = bestFrom
+			}
+		}
+		prev, cur = cur, prev
+	}
+
+	end, score := -1, none
+	for j := 0; j < n; j++ {
+		if prev[j] > score {
+			end, score = j, prev[j]
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+
+	positions := make([]int, m)
+	for i, j := m-1, end; i >= 0; i-- {
+		positions[i] = j
+		j = from[i][j]
+	}
+	return score, positions, true
+}
+
+func isSubsequence(pattern, candidate string) bool {
+	i := 0
+	for j := 0; j < len(candidate) && i < len(pattern); j++ {
+		if lower(candidate[j]) == pattern[i] {
+			i++
+		}
+	}
+	return i == len(pattern)
+}
+
+func charBonus(s string, j int) int {
+	if j == 0 {
+		return bonusBoundary
+	}
+	switch prev := s[j-1]; {
+	case prev == '/' || prev == '_' || prev == '-' || prev == '.' || prev == ' ':
+		return bonusBoundary
+	case prev >= 'a' && prev <= 'z' && s[j] >= 'A' && s[j] <= 'Z':
+		return bonusCamel
+	}
+	return 0
+}
+
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+	return c
+}
@@ -0,0 +1,62 @@
+package git
+
+import (
+	"os/exec"
+	"path"
+	"strings"
+)
+
+// FileStatuses returns the status letter of every changed file keyed by its
+// path relative to dir: the unstaged status when there is one, else the
+// staged one, and "?" for untracked files. Directories containing changes
+// are included with "M". Returns nil outside of a git repository.
+func FileStatuses(dir string) map[string]string {
+	// Only the three listings are run, not GetStatus with its branch and
+	// upstream lookups. --relative limits the diffs to dir and gives
+	// paths relative to it, like ls-files does.
+	output := func(args ...string) (string, bool) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		return string(out), err == nil
+	}
+	unstagedOut, ok := output("diff", "--name-status", "--relative")
+	if !ok {
+		return nil
+	}
+	stagedOut, ok := output("diff", "--cached", "--name-status", "--relative")
+	if !ok {
+		return nil
+	}
+	untrackedOut, ok := output("ls-files", "--others", "--exclude-standard")
+	if !ok {
+		return nil
+	}
+
+	statuses := map[string]string{}
+	add := func(file, letter string) {
+		if _, ok := statuses[file]; ok {
+			return
+		}
+		statuses[file] = letter
+		for dir := path.Dir(file); dir != "." && dir != "/"; dir = path.Dir(dir) {
+			if _, ok := statuses[dir]; ok {
+				break
+			}
+			statuses[dir] = "M"
+		}
+	}
+	for _, out := range []string{unstagedOut, stagedOut} {
+		for _, f := range parseFileStatus(out) {
+			if f.Status != "" {
+				add(f.File, f.Status[:1])
+			}
+		}
+	}
+	for _, file := range strings.Split(strings.TrimSpace(untrackedOut), "\n") {
+		if file != "" {
+			add(file, "?")
+		}
+	}
+	return statuses
+}
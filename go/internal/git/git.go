@@ -4,8 +4,6 @@ import (
 	"bytes"
 	"os/exec"
 	"strings"
-
-	"github.com/c00d-ide/c00d/internal/config"
 )
 
 // Status returns the current git status
@@ -24,19 +22,19 @@ type FileStatus struct {
 	File   string `json:"file"`
 }
 
-// GetStatus returns the current git status
-func GetStatus() (*Status, error) {
+// GetStatus returns the git status of the repository at dir
+func GetStatus(dir string) (*Status, error) {
 	status := &Status{}
 
 	// Get current branch
 	cmd := exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
-	cmd.Dir = config.C.BasePath
+	cmd.Dir = dir
 	branchOut, _ := cmd.Output()
 	status.Branch = strings.TrimSpace(string(branchOut))
 
 	// Get ahead/behind
 	cmd = exec.Command("git", "rev-list", "--left-right", "--count", "HEAD...@{upstream}")
-	cmd.Dir = config.C.BasePath
+	cmd.Dir = dir
 	countOut, err := cmd.Output()
 	if err == nil {
 		parts := strings.Fields(string(countOut))
@@ -48,19 +46,19 @@ func GetStatus() (*Status, error) {
 
 	// Get staged files
 	cmd = exec.Command("git", "diff", "--cached", "--name-status")
-	cmd.Dir = config.C.BasePath
+	cmd.Dir = dir
 	stagedOut, _ := cmd.Output()
 	status.Staged = parseFileStatus(string(stagedOut))
 
 	// Get unstaged files
 	cmd = exec.Command("git", "diff", "--name-status")
-	cmd.Dir = config.C.BasePath
+	cmd.Dir = dir
 	unstagedOut, _ := cmd.Output()
 	status.Unstaged = parseFileStatus(string(unstagedOut))
 
 	// Get untracked files
 	cmd = exec.Command("git", "ls-files", "--others", "--exclude-standard")
-	cmd.Dir = config.C.BasePath
+	cmd.Dir = dir
 	untrackedOut, _ := cmd.Output()
 	status.Untracked = []string{}
 	for _, line := range strings.Split(strings.TrimSpace(string(untrackedOut)), "\n") {
@@ -78,21 +76,23 @@ func parseFileStatus(output string) []FileStatus {
 		if line == "" {
 			continue
 		}
-		parts := strings.Fields(line)
+		// Fields are tab separated; renames and copies list the old and
+		// the new path, keep the new one
+		parts := strings.Split(line, "\t")
 		if len(parts) >= 2 {
 			result = append(result, FileStatus{
 				Status: parts[0],
-				File:   parts[1],
+				File:   parts[len(parts)-1],
 			})
 		}
 	}
 	return result
 }
 
-// RunCommand executes a git command and returns the output
-func RunCommand(args ...string) (string, int, error) {
+// RunCommand executes a git command in dir and returns the output
+func RunCommand(dir string, args ...string) (string, int, error) {
 	cmd := exec.Command("git", args...)
-	cmd.Dir = config.C.BasePath
+	cmd.Dir = dir
 
 	var output bytes.Buffer
 	cmd.Stdout = &output
@@ -110,42 +110,42 @@ func RunCommand(args ...string) (string, int, error) {
 }
 
 // Stage adds a file to the staging area
-func Stage(file string) (string, int, error) {
-	return RunCommand("add", "--", file)
+func Stage(dir, file string) (string, int, error) {
+	return RunCommand(dir, "add", "--", file)
 }
 
 // Unstage removes a file from the staging area
-func Unstage(file string) (string, int, error) {
-	return RunCommand("restore", "--staged", "--", file)
+func Unstage(dir, file string) (string, int, error) {
+	return RunCommand(dir, "restore", "--staged", "--", file)
 }
 
 // StageAll stages all changes
-func StageAll() (string, int, error) {
-	return RunCommand("add", "-A")
+func StageAll(dir string) (string, int, error) {
+	return RunCommand(dir, "add", "-A")
 }
 
 // UnstageAll unstages all changes
-func UnstageAll() (string, int, error) {
-	return RunCommand("restore", "--staged", ".")
+func UnstageAll(dir string) (string, int, error) {
+	return RunCommand(dir, "restore", "--staged", ".")
 }
 
 // Commit creates a new commit
-func Commit(message string) (string, int, error) {
-	return RunCommand("commit", "-m", message)
+func Commit(dir, message string) (string, int, error) {
+	return RunCommand(dir, "commit", "-m", message)
 }
 
 // Push pushes to the remote
-func Push() (string, int, error) {
-	return RunCommand("push")
+func Push(dir string) (string, int, error) {
+	return RunCommand(dir, "push")
 }
 
 // Pull pulls from the remote
-func Pull() (string, int, error) {
-	return RunCommand("pull")
+func Pull(dir string) (string, int, error) {
+	return RunCommand(dir, "pull")
 }
 
 // Diff shows the diff
-func Diff(staged bool, file string) (string, int, error) {
+func Diff(dir string, staged bool, file string) (string, int, error) {
 	args := []string{"diff"}
 	if staged {
 		args = append(args, "--staged")
@@ -153,10 +153,10 @@ func Diff(staged bool, file string) (string, int, error) {
 	if file != "" {
 		args = append(args, "--", file)
 	}
-	return RunCommand(args...)
+	return RunCommand(dir, args...)
 }
 
 // Discard discards changes to a file
-func Discard(file string) (string, int, error) {
-	return RunCommand("checkout", "--", file)
+func Discard(dir, file string) (string, int, error) {
+	return RunCommand(dir, "checkout", "--", file)
 }
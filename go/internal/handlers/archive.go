@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/c00d-ide/c00d/internal/archive"
+	"github.com/c00d-ide/c00d/internal/config"
+	"github.com/c00d-ide/c00d/internal/ignore"
+	"github.com/c00d-ide/c00d/internal/workspace"
+)
+
+// Archive handles downloading a directory as a zip or tar.gz (GET) and
+// extracting an archive on the server into a directory (POST)
+func Archive(w http.ResponseWriter, r *http.Request) {
+	ws := workspace.From(r)
+
+	switch r.Method {
+	case "GET":
+		// Stream a directory as an archive
+		fullPath, ok := ws.ValidatePath(r.URL.Query().Get("path"))
+		if !ok {
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+			return
+		}
+		if info, err := os.Stat(fullPath); err != nil || !info.IsDir() {
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, `{"error":"not a directory"}`, http.StatusBadRequest)
+			return
+		}
+
+		format := r.URL.Query().Get("format")
+		contentType := "application/gzip"
+		switch format {
+		case "", archive.Zip:
+			format, contentType = archive.Zip, "application/zip"
+		case "tgz", archive.TarGz:
+			format = archive.TarGz
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, `{"error":"format must be zip or tar.gz"}`, http.StatusBadRequest)
+			return
+		}
+
+		name := filepath.Base(fullPath)
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+			"filename": name + "." + format,
+		}))
+		// Headers are already sent once streaming starts, so errors can only be logged
+		if err := archive.Write(w, format, fullPath, name, ignore.For(ws.Path).MatchPath); err != nil {
+			log.Printf("archive of %s failed: %v", fullPath, err)
+		}
+
+	case "POST":
+		// Extract an archive, into its own directory unless "dest" is given
+		w.Header().Set("Content-Type", "application/json")
+
+		var req struct {
+			Path      string `json:"path"`
+			Dest      string `json:"dest"`
+			Overwrite bool   `json:"overwrite"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		archivePath, ok := ws.ValidatePath(req.Path)
+		if !ok {
+			http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+			return
+		}
+		dest := filepath.Dir(archivePath)
+		if req.Dest != "" {
+			dest, ok = ws.ValidatePath(req.Dest)
+		}
+		if !ok || ws.Protected(dest) {
+			http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+			return
+		}
+		if err := os.MkdirAll(dest, 0755); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+			return
+		}
+
+		result, err := archive.Extract(archivePath, dest, req.Overwrite, config.C.Files.MaxExtract, ws.Protected, func(fullPath string) func() {
+			// Lock and keep the file an entry replaces, as saves do
+			unlock := lockFile(fullPath)
+			if info, err := os.Lstat(fullPath); err == nil && info.Mode().IsRegular() && req.Overwrite {
+				snapshotBeforeWrite(ws, ws.Rel(fullPath), fullPath)
+			}
+			return unlock
+		})
+		if err != nil {
+			code := http.StatusInternalServerError
+			switch {
+			case errors.Is(err, archive.ErrFormat):
+				code = http.StatusBadRequest
+			case errors.Is(err, archive.ErrTooLarge):
+				code = http.StatusRequestEntityTooLarge
+			case os.IsNotExist(err):
+				code = http.StatusNotFound
+			}
+			w.WriteHeader(code)
+			// Report what was unpacked before the failure
+			json.NewEncoder(w).Encode(map[string]any{"error": err.Error(), "result": result})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"success":   true,
+			"dest":      ws.Rel(dest),
+			"extracted": result.Extracted,
+			"skipped":   result.Skipped,
+			"size":      result.Size,
+		})
+
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
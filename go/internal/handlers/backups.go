@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/c00d-ide/c00d/internal/backup"
+	"github.com/c00d-ide/c00d/internal/fileutil"
+	"github.com/c00d-ide/c00d/internal/history"
+	"github.com/c00d-ide/c00d/internal/workspace"
+)
+
+// FileBackups handles listing and restoring previous versions of a file
+func FileBackups(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ws := workspace.From(r)
+
+	switch r.Method {
+	case "GET":
+		// List backups
+		fullPath, ok := ws.ValidatePath(r.URL.Query().Get("path"))
+		if !ok {
+			http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+			return
+		}
+		backups, err := backup.List(ws.DataDir, ws.Rel(fullPath))
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"path":    r.URL.Query().Get("path"),
+			"backups": backups,
+		})
+
+	case "POST":
+		// Restore a backup over the current file
+		var req struct {
+			Path string `json:"path"`
+			ID   string `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		fullPath, ok := ws.ValidatePath(req.Path)
+		if !ok || ws.Protected(fullPath) {
+			http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+			return
+		}
+
+		unlock := lockFile(fullPath)
+		defer unlock()
+
+		rel := ws.Rel(fullPath)
+		content, err := backup.Read(ws.DataDir, rel, req.ID)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusNotFound)
+			return
+		}
+
+		// Keep the version being replaced too, so a restore can be undone
+		snapshotBeforeWrite(ws, rel, fullPath)
+
+		if err := fileutil.WriteFileAtomic(fullPath, content, 0644); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+			return
+		}
+		history.Record(ws.Path, rel, history.EventRestore, content)
+		result := map[string]any{
+			"success": true,
+			"version": contentVersion(content),
+		}
+		addContent(result, fullPath, content, false)
+		json.NewEncoder(w).Encode(result)
+
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
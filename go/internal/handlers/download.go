@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/c00d-ide/c00d/internal/workspace"
+)
+
+// Download streams a file to the client as an attachment. Range requests
+// are supported, so large downloads can resume.
+func Download(w http.ResponseWriter, r *http.Request) {
+	ws := workspace.From(r)
+
+	fullPath, ok := ws.ValidatePath(r.URL.Query().Get("path"))
+	if !ok {
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+		return
+	}
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error":"file not found"}`, http.StatusNotFound)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		w.Header().Set("Content-Type", "application/json")
+		http.Error(w, `{"error":"not a file"}`, http.StatusBadRequest)
+		return
+	}
+
+	disposition := "attachment"
+	if r.URL.Query().Get("inline") != "" {
+		disposition = "inline"
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{
+		"filename": filepath.Base(fullPath),
+	}))
+	// Files are served from the IDE's origin; never let one run scripts there
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
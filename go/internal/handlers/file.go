@@ -1,25 +1,45 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"unicode/utf8"
 
+	"github.com/c00d-ide/c00d/internal/backup"
 	"github.com/c00d-ide/c00d/internal/config"
+	"github.com/c00d-ide/c00d/internal/db"
+	"github.com/c00d-ide/c00d/internal/fileutil"
+	"github.com/c00d-ide/c00d/internal/history"
+	"github.com/c00d-ide/c00d/internal/language"
+	"github.com/c00d-ide/c00d/internal/trash"
+	"github.com/c00d-ide/c00d/internal/workspace"
 )
 
-// File handles single file operations (read, write, delete, rename)
+// File handles single file operations (read, write, delete, rename, copy,
+// chmod, mkdir, symlink)
 func File(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	ws := workspace.From(r)
 
 	path := r.URL.Query().Get("path")
-	fullPath := filepath.Join(config.C.BasePath, path)
 
-	// Security check
-	if !strings.HasPrefix(fullPath, config.C.BasePath) {
+	// Deleting, renaming and copying act on a symlink itself rather than
+	// its target, so these may name links that lead outside the workspace
+	validate := ws.ValidatePath
+	if r.Method == "DELETE" || r.Method == "PATCH" {
+		validate = ws.ValidateLinkPath
+	}
+	fullPath, ok := validate(path)
+	if !ok {
 		http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
 		return
 	}
@@ -27,58 +47,211 @@ func File(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
 	case "GET":
 		// Read file
+		info, err := os.Stat(fullPath)
+		if err != nil {
+			code := http.StatusInternalServerError
+			if os.IsNotExist(err) {
+				code = http.StatusNotFound
+			}
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), code)
+			return
+		}
+		if info.IsDir() {
+			http.Error(w, `{"error":"path is a directory"}`, http.StatusBadRequest)
+			return
+		}
+
+		q := r.URL.Query()
+		switch {
+		case q.Get("stat") != "":
+			json.NewEncoder(w).Encode(fileStat(path, info))
+			return
+		case q.Get("follow") != "":
+			followFile(w, r, fullPath, info)
+			return
+		case q.Get("line") != "" || q.Get("lines") != "" || q.Get("tail") != "":
+			readLineRange(w, r, path, fullPath, info)
+			return
+		case q.Get("offset") != "" || q.Get("length") != "":
+			readByteRange(w, r, path, fullPath, info)
+			return
+		}
+
+		// Whole files are loaded into memory, so huge ones must be paged
+		if limit := config.C.Files.MaxReadSize; limit > 0 && info.Size() > limit {
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			json.NewEncoder(w).Encode(map[string]any{
+				"error":         "file too large to open whole; read it in ranges",
+				"too_large":     true,
+				"size":          info.Size(),
+				"max_read_size": limit,
+			})
+			return
+		}
+
 		content, err := os.ReadFile(fullPath)
 		if err != nil {
 			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
 			return
 		}
-		json.NewEncoder(w).Encode(map[string]any{
-			"path":    path,
-			"content": string(content),
-		})
+		version := contentVersion(content)
+		w.Header().Set("ETag", `"`+version+`"`)
+		result := map[string]any{
+			"path":     path,
+			"size":     len(content),
+			"version":  version,
+			"language": language.Detect(fullPath),
+		}
+		addContent(result, fullPath, content, r.URL.Query().Get("encoding") == "base64")
+		json.NewEncoder(w).Encode(result)
+
+		// Track recent file, for ranking in the file finder
+		db.AddRecentFile(fullPath)
 
 	case "POST", "PUT":
 		// Write file
 		var req struct {
-			Content string `json:"content"`
+			Content  string `json:"content"`
+			Encoding string `json:"encoding"` // "base64" for binary content, otherwise UTF-8 text
+			Version  string `json:"version"`  // Same as If-Match: version the edit was based on
 		}
 		json.NewDecoder(r.Body).Decode(&req)
 
+		if ws.Protected(fullPath) {
+			http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+			return
+		}
+
+		data := []byte(req.Content)
+		if req.Encoding == "base64" {
+			decoded, err := base64.StdEncoding.DecodeString(req.Content)
+			if err != nil {
+				http.Error(w, `{"error":"invalid base64 content"}`, http.StatusBadRequest)
+				return
+			}
+			data = decoded
+		}
+
+		expected := strings.Trim(r.Header.Get("If-Match"), `"`)
+		if expected == "" {
+			expected = req.Version
+		}
+
+		// Hold the lock so no other save lands between the check and the write
+		unlock := lockFile(fullPath)
+		defer unlock()
+
+		if expected != "" {
+			current, err := os.ReadFile(fullPath)
+			exists := err == nil
+			if err != nil && !os.IsNotExist(err) {
+				http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+				return
+			}
+			if !exists || (expected != "*" && expected != contentVersion(current)) {
+				// Someone else changed the file; send theirs back for merging
+				w.WriteHeader(http.StatusConflict)
+				result := map[string]any{
+					"error":  "file changed on server",
+					"exists": exists,
+				}
+				if exists {
+					addContent(result, fullPath, current, false)
+					result["version"] = contentVersion(current)
+				}
+				json.NewEncoder(w).Encode(result)
+				return
+			}
+		}
+
 		// Ensure directory exists
 		os.MkdirAll(filepath.Dir(fullPath), 0755)
 
-		err := os.WriteFile(fullPath, []byte(req.Content), 0644)
+		// Keep the previous version
+		rel := ws.Rel(fullPath)
+		snapshotBeforeWrite(ws, rel, fullPath)
+
+		err := fileutil.WriteFileAtomic(fullPath, data, 0644)
 		if err != nil {
 			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
 			return
 		}
-		json.NewEncoder(w).Encode(map[string]any{"success": true})
+		if err := history.Record(ws.Path, rel, history.EventSave, data); err != nil {
+			log.Printf("history of %s failed: %v", fullPath, err)
+		}
+		version := contentVersion(data)
+		w.Header().Set("ETag", `"`+version+`"`)
+		json.NewEncoder(w).Encode(map[string]any{"success": true, "version": version})
 
 	case "DELETE":
-		// Delete file
-		err := os.RemoveAll(fullPath)
+		// Delete file or directory
+		if trash.Protected(fullPath) || ws.Protected(fullPath) {
+			http.Error(w, `{"error":"cannot delete this path"}`, http.StatusForbidden)
+			return
+		}
+		// Snapshot it first so it can also be restored from history
+		if err := history.RecordDelete(ws.Path, ws.Rel(fullPath), fullPath); err != nil {
+			log.Printf("history of %s failed: %v", fullPath, err)
+		}
+		if !config.ShouldUseTrash() {
+			if err := os.RemoveAll(fullPath); err != nil {
+				http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]any{"success": true})
+			return
+		}
+
+		// Move to the trash, from where it can be restored until it expires
+		item, err := trash.Move(ws.Path, ws.Rel(fullPath), fullPath)
 		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+			code := http.StatusInternalServerError
+			if os.IsNotExist(err) {
+				code = http.StatusNotFound
+			}
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), code)
 			return
 		}
-		json.NewEncoder(w).Encode(map[string]any{"success": true})
+		json.NewEncoder(w).Encode(map[string]any{"success": true, "trash_id": item.ID})
 
 	case "PATCH":
-		// Rename file
-		var req struct {
-			NewPath string `json:"new_path"`
-		}
+		var req fileOp
 		json.NewDecoder(r.Body).Decode(&req)
 
+		switch req.Action {
+		case "", "rename":
+		case "copy":
+			copyPath(w, ws, fullPath, req)
+			return
+		case "duplicate":
+			duplicatePath(w, ws, path, fullPath)
+			return
+		case "chmod":
+			chmodPath(w, ws, fullPath, req)
+			return
+		case "mkdir":
+			mkdirPath(w, ws, path)
+			return
+		case "symlink":
+			symlinkPath(w, ws, path, req)
+			return
+		default:
+			http.Error(w, `{"error":"invalid action"}`, http.StatusBadRequest)
+			return
+		}
+
+		// Rename file
+		if ws.Protected(fullPath) {
+			http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+			return
+		}
 		if req.NewPath == "" {
 			http.Error(w, `{"error":"new_path is required"}`, http.StatusBadRequest)
 			return
 		}
 
-		newFullPath := filepath.Join(config.C.BasePath, req.NewPath)
-		// Security check for new path
-		if !strings.HasPrefix(newFullPath, config.C.BasePath) {
-			http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+		newFullPath, ok := opDest(w, ws, req.NewPath)
+		if !ok {
 			return
 		}
 
@@ -90,6 +263,85 @@ func File(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
 			return
 		}
+		if err := history.RecordRename(ws.Path, ws.Rel(fullPath), ws.Rel(newFullPath)); err != nil {
+			log.Printf("history of %s failed: %v", fullPath, err)
+		}
 		json.NewEncoder(w).Encode(map[string]any{"success": true, "new_path": req.NewPath})
 	}
 }
+
+// addContent adds file content to a JSON response with its MIME type.
+// Binary content, text that isn't valid UTF-8 past what IsBinary looks at,
+// or any content when base64Only is set, is sent base64 encoded so it
+// survives JSON intact.
+func addContent(result map[string]any, name string, content []byte, base64Only bool) {
+	binary := fileutil.IsBinary(content)
+	result["binary"] = binary
+	result["mime"] = fileutil.DetectMIME(name, content)
+	if binary || base64Only || !utf8.Valid(content) {
+		result["encoding"] = "base64"
+		result["content"] = base64.StdEncoding.EncodeToString(content)
+	} else {
+		result["encoding"] = "utf-8"
+		result["content"] = string(content)
+	}
+}
+
+// contentVersion returns the version of file content used for ETags and
+// If-Match preconditions
+func contentVersion(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:16])
+}
+
+// snapshotBeforeWrite keeps the content of fullPath that a write is about
+// to replace: in its history, or as a backup when history is off, so each
+// version is stored once. Failures are logged and don't stop the write.
+func snapshotBeforeWrite(ws *workspace.Workspace, rel, fullPath string) {
+	if config.ShouldKeepHistory() {
+		if err := history.BeforeWrite(ws.Path, rel, fullPath); err != nil {
+			log.Printf("history of %s failed: %v", fullPath, err)
+		}
+		return
+	}
+	if err := backup.Save(ws.DataDir, rel, fullPath); err != nil {
+		log.Printf("backup of %s failed: %v", fullPath, err)
+	}
+}
+
+var (
+	fileLocksMu sync.Mutex
+	fileLocks   = map[string]*fileLock{} // Resolved path -> lock
+)
+
+// fileLock is the write lock of one file
+type fileLock struct {
+	mu   sync.Mutex
+	refs int // Holders and waiters
+}
+
+// lockFile serializes writes to a file and returns the unlock function.
+// The path is resolved first, so symlinks to one file share its lock, and
+// a lock is dropped once nobody holds or waits for it.
+func lockFile(fullPath string) func() {
+	key := fileutil.RealPath(fullPath)
+	fileLocksMu.Lock()
+	l := fileLocks[key]
+	if l == nil {
+		l = &fileLock{}
+		fileLocks[key] = l
+	}
+	l.refs++
+	fileLocksMu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		fileLocksMu.Lock()
+		l.refs--
+		if l.refs == 0 {
+			delete(fileLocks, key)
+		}
+		fileLocksMu.Unlock()
+	}
+}
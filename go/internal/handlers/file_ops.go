@@ -0,0 +1,252 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/c00d-ide/c00d/internal/fileutil"
+	"github.com/c00d-ide/c00d/internal/history"
+	"github.com/c00d-ide/c00d/internal/security"
+	"github.com/c00d-ide/c00d/internal/workspace"
+)
+
+// fileOp is the body of a PATCH request to the file API
+type fileOp struct {
+	Action    string `json:"action"`    // rename (default), copy, duplicate, chmod, mkdir, symlink
+	NewPath   string `json:"new_path"`  // rename, copy
+	Overwrite bool   `json:"overwrite"` // copy: replace an existing file
+	Mode      string `json:"mode"`      // chmod: octal like "755", or "+x" / "-x"
+	Target    string `json:"target"`    // symlink: what the link points to
+}
+
+// opDest validates a destination path of a file operation, writing an
+// error and returning false if it can't be written to
+func opDest(w http.ResponseWriter, ws *workspace.Workspace, path string) (string, bool) {
+	fullPath, ok := ws.ValidatePath(path)
+	if !ok || fullPath == ws.Path || ws.Protected(fullPath) {
+		http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+		return "", false
+	}
+	return fullPath, true
+}
+
+// opError writes a file operation error with a status matching its cause
+func opError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		code = http.StatusNotFound
+	case errors.Is(err, os.ErrExist):
+		code = http.StatusConflict
+	}
+	http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), code)
+}
+
+// existsError writes a conflict for a destination that already exists
+func existsError(w http.ResponseWriter, path string) {
+	http.Error(w, fmt.Sprintf(`{"error":"%s already exists","exists":true}`, path), http.StatusConflict)
+}
+
+// copyPath copies the file or directory at fullPath to req.NewPath
+func copyPath(w http.ResponseWriter, ws *workspace.Workspace, fullPath string, req fileOp) {
+	if req.NewPath == "" {
+		http.Error(w, `{"error":"new_path is required"}`, http.StatusBadRequest)
+		return
+	}
+	dest, ok := opDest(w, ws, req.NewPath)
+	if !ok {
+		return
+	}
+	copyTo(w, ws, fullPath, dest, req.NewPath, req.Overwrite)
+}
+
+// duplicatePath copies the file or directory at fullPath next to itself
+// as "name copy.ext", "name copy 2.ext" and so on
+func duplicatePath(w http.ResponseWriter, ws *workspace.Workspace, path, fullPath string) {
+	dir, name := filepath.Split(path)
+	ext := filepath.Ext(name)
+	stem := strings.TrimSuffix(name, ext)
+	if stem == "" { // Dotfiles like .env have no extension
+		stem, ext = name, ""
+	}
+
+	for i := 1; i <= 1000; i++ {
+		copyName := stem + " copy" + ext
+		if i > 1 {
+			copyName = fmt.Sprintf("%s copy %d%s", stem, i, ext)
+		}
+		newPath := filepath.Join(dir, copyName)
+		dest, ok := opDest(w, ws, newPath)
+		if !ok {
+			return
+		}
+		if _, err := os.Lstat(dest); os.IsNotExist(err) {
+			copyTo(w, ws, fullPath, dest, newPath, false)
+			return
+		}
+	}
+	http.Error(w, `{"error":"too many copies"}`, http.StatusConflict)
+}
+
+// copyTo copies src to dest. An existing destination is a conflict unless
+// overwrite is set and both are files, in which case it is replaced
+// atomically and its previous content kept in backups and history.
+func copyTo(w http.ResponseWriter, ws *workspace.Workspace, src, dest, newPath string, overwrite bool) {
+	info, err := os.Lstat(src)
+	if err != nil {
+		opError(w, err)
+		return
+	}
+	if security.WithinDir(src, dest) {
+		http.Error(w, `{"error":"cannot copy a directory into itself"}`, http.StatusBadRequest)
+		return
+	}
+
+	if existing, err := os.Lstat(dest); err == nil {
+		if !overwrite || !existing.Mode().IsRegular() || !info.Mode().IsRegular() {
+			existsError(w, newPath)
+			return
+		}
+		if err := replaceFile(ws, src, dest, info.Mode().Perm()); err != nil {
+			opError(w, err)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"success": true, "new_path": newPath})
+		return
+	}
+
+	os.MkdirAll(filepath.Dir(dest), 0755)
+	if err := fileutil.CopyTree(src, dest); err != nil {
+		if !errors.Is(err, os.ErrExist) {
+			os.RemoveAll(dest) // Don't leave a partial copy behind
+		}
+		opError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "new_path": newPath})
+}
+
+// replaceFile overwrites the file dest with the content of src
+func replaceFile(ws *workspace.Workspace, src, dest string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	unlock := lockFile(dest)
+	defer unlock()
+
+	rel := ws.Rel(dest)
+	snapshotBeforeWrite(ws, rel, dest)
+	if _, err := fileutil.WriteReaderAtomic(dest, in, perm); err != nil {
+		return err
+	}
+	if err := history.RecordFile(ws.Path, rel, history.EventSave, dest); err != nil {
+		log.Printf("history of %s failed: %v", dest, err)
+	}
+	return nil
+}
+
+// chmodPath changes the permission bits of the file or directory at
+// fullPath. Symlinks are refused, as chmod would change their target, and
+// so is anything in a data directory.
+func chmodPath(w http.ResponseWriter, ws *workspace.Workspace, fullPath string, req fileOp) {
+	if ws.Protected(fullPath) {
+		http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+		return
+	}
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		opError(w, err)
+		return
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		http.Error(w, `{"error":"cannot change the mode of a symlink"}`, http.StatusBadRequest)
+		return
+	}
+
+	perm := info.Mode().Perm()
+	switch req.Mode {
+	case "+x":
+		perm |= 0111
+	case "-x":
+		perm &^= 0111
+	default:
+		mode, err := strconv.ParseUint(req.Mode, 8, 32)
+		if err != nil || mode > 0777 {
+			http.Error(w, `{"error":"mode must be octal permissions like 644, +x or -x"}`, http.StatusBadRequest)
+			return
+		}
+		perm = os.FileMode(mode)
+	}
+
+	if err := os.Chmod(fullPath, perm); err != nil {
+		opError(w, err)
+		return
+	}
+	info, _ = os.Stat(fullPath)
+	json.NewEncoder(w).Encode(map[string]any{
+		"success": true,
+		"mode":    info.Mode().String(),
+		"perm":    fmt.Sprintf("%04o", info.Mode().Perm()),
+	})
+}
+
+// mkdirPath creates the directory at path, along with missing parents
+func mkdirPath(w http.ResponseWriter, ws *workspace.Workspace, path string) {
+	fullPath, ok := opDest(w, ws, path)
+	if !ok {
+		return
+	}
+	if _, err := os.Lstat(fullPath); err == nil {
+		existsError(w, path)
+		return
+	}
+	if err := os.MkdirAll(fullPath, 0755); err != nil {
+		opError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "path": path})
+}
+
+// symlinkPath creates a symlink at path pointing to req.Target, which is
+// relative to the link's directory unless absolute and must resolve to a
+// path within the workspace
+func symlinkPath(w http.ResponseWriter, ws *workspace.Workspace, path string, req fileOp) {
+	if req.Target == "" {
+		http.Error(w, `{"error":"target is required"}`, http.StatusBadRequest)
+		return
+	}
+	fullPath, ok := opDest(w, ws, path)
+	if !ok {
+		return
+	}
+
+	target := req.Target
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(fullPath), target)
+	}
+	if !ws.ValidateFullPath(target) {
+		http.Error(w, `{"error":"symlink target is outside the workspace"}`, http.StatusForbidden)
+		return
+	}
+
+	if _, err := os.Lstat(fullPath); err == nil {
+		existsError(w, path)
+		return
+	}
+	os.MkdirAll(filepath.Dir(fullPath), 0755)
+	if err := os.Symlink(req.Target, fullPath); err != nil {
+		opError(w, err)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "path": path, "target": req.Target})
+}
@@ -0,0 +1,299 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/c00d-ide/c00d/internal/config"
+	"github.com/c00d-ide/c00d/internal/fileutil"
+)
+
+const (
+	defaultRangeLines = 1000
+	maxRangeLines     = 10000
+	followInterval    = 500 * time.Millisecond
+	followChunk       = 64 * 1024
+)
+
+// rangeLimit returns the most bytes a single read may return
+func rangeLimit() int64 {
+	if limit := config.C.Files.MaxReadSize; limit > 0 {
+		return limit
+	}
+	return 1 << 62
+}
+
+// queryInt64 parses an integer query parameter, returning def if it's absent
+func queryInt64(r *http.Request, name string, def int64) (int64, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid %s", name)
+	}
+	return n, nil
+}
+
+// fileStat returns the size and modification time of a file, for responses
+// that don't carry the whole content
+func fileStat(path string, info os.FileInfo) map[string]any {
+	return map[string]any{
+		"path":     path,
+		"size":     info.Size(),
+		"mod_time": info.ModTime().Format(time.RFC3339),
+	}
+}
+
+// readByteRange returns up to length bytes of the file starting at offset.
+// A multi-byte character cut off at the end of a text range is left for the
+// next read, so paging through a file never splits one.
+func readByteRange(w http.ResponseWriter, r *http.Request, path, fullPath string, info os.FileInfo) {
+	offset, err := queryInt64(r, "offset", 0)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+	length, err := queryInt64(r, "length", 64*1024)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+	length = min(length, rangeLimit())
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	buf := make([]byte, max(min(length, info.Size()-offset), 0))
+	n, err := f.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+		return
+	}
+	buf = buf[:n]
+	if offset+int64(n) < info.Size() {
+		if text, _ := fileutil.SplitUTF8(buf); len(text) > 0 {
+			buf = text
+		}
+	}
+
+	result := fileStat(path, info)
+	result["offset"] = offset
+	result["length"] = len(buf)
+	result["next_offset"] = offset + int64(len(buf))
+	result["eof"] = offset+int64(len(buf)) >= info.Size()
+	addContent(result, fullPath, buf, r.URL.Query().Get("encoding") == "base64")
+	json.NewEncoder(w).Encode(result)
+}
+
+// readLineRange returns a page of lines. Pages start at line "line"
+// (1-based), or at byte "offset" when continuing from a previous page's
+// next_offset, which avoids rescanning the file from the top. "tail"
+// returns the last N lines instead.
+func readLineRange(w http.ResponseWriter, r *http.Request, path, fullPath string, info os.FileInfo) {
+	q := r.URL.Query()
+	count, err := queryInt64(r, "lines", defaultRangeLines)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+	line, err := queryInt64(r, "line", 1)
+	if err != nil || line == 0 {
+		http.Error(w, `{"error":"invalid line"}`, http.StatusBadRequest)
+		return
+	}
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+		return
+	}
+	defer f.Close()
+
+	var offset int64
+	switch {
+	case q.Get("tail") != "":
+		tail, err := queryInt64(r, "tail", 0)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+			return
+		}
+		count = tail
+		offset, err = tailOffset(f, info.Size(), min(tail, maxRangeLines))
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+			return
+		}
+		line = 0 // Unknown without counting from the top
+
+	case q.Get("offset") != "":
+		offset, err = queryInt64(r, "offset", 0)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+			return
+		}
+	}
+	count = min(max(count, 1), maxRangeLines)
+
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+		return
+	}
+	reader := bufio.NewReaderSize(f, 64*1024)
+
+	// Skip to the first requested line when starting from the top
+	if q.Get("offset") == "" && q.Get("tail") == "" {
+		for n := int64(1); n < line; n++ {
+			skipped, err := skipLine(reader)
+			offset += skipped
+			if err != nil {
+				break
+			}
+		}
+	}
+
+	var content bytes.Buffer
+	limit := rangeLimit()
+	returned := int64(0)
+	for returned < count && int64(content.Len()) < limit {
+		text, err := reader.ReadSlice('\n')
+		if len(text) > 0 {
+			content.Write(text)
+			if text[len(text)-1] == '\n' || err == io.EOF {
+				returned++
+			}
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			break
+		}
+	}
+	data := content.Bytes()
+	if int64(len(data)) > limit {
+		data = data[:limit]
+	}
+
+	result := fileStat(path, info)
+	result["line"] = line
+	result["lines"] = returned
+	result["offset"] = offset
+	result["next_offset"] = offset + int64(len(data))
+	result["eof"] = offset+int64(len(data)) >= info.Size()
+	addContent(result, fullPath, data, q.Get("encoding") == "base64")
+	json.NewEncoder(w).Encode(result)
+}
+
+// skipLine reads past the next newline, returning how many bytes it skipped
+func skipLine(reader *bufio.Reader) (int64, error) {
+	var skipped int64
+	for {
+		text, err := reader.ReadSlice('\n')
+		skipped += int64(len(text))
+		if err != bufio.ErrBufferFull {
+			return skipped, err
+		}
+	}
+}
+
+// tailOffset returns the offset where the last n lines of a file start,
+// reading backwards from the end. A final newline doesn't start a line.
+func tailOffset(f *os.File, size, n int64) (int64, error) {
+	if n == 0 {
+		return size, nil
+	}
+	buf := make([]byte, 64*1024)
+	end := size
+	newlines := int64(0)
+	for end > 0 {
+		start := max(end-int64(len(buf)), 0)
+		chunk := buf[:end-start]
+		if _, err := f.ReadAt(chunk, start); err != nil && err != io.EOF {
+			return 0, err
+		}
+		for i := len(chunk) - 1; i >= 0; i-- {
+			if chunk[i] != '\n' || start+int64(i) == size-1 {
+				continue
+			}
+			newlines++
+			if newlines == n {
+				return start + int64(i) + 1, nil
+			}
+		}
+		end = start
+	}
+	return 0, nil
+}
+
+// followFile streams data appended to a file, like tail -f, as
+// newline-delimited JSON until the client disconnects. It starts at
+// "offset", or at the current end of the file. If the file shrinks, it was
+// truncated or rotated, and following restarts from the beginning.
+func followFile(w http.ResponseWriter, r *http.Request, fullPath string, info os.FileInfo) {
+	offset, err := queryInt64(r, "offset", info.Size())
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	stream := newNDJSONStream(w)
+	if stream.Send(map[string]any{"type": "start", "offset": offset, "size": info.Size()}) != nil {
+		return
+	}
+
+	var pending []byte // Incomplete UTF-8 character held back for the next read
+	buf := make([]byte, followChunk)
+	ticker := time.NewTicker(followInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		f, err := os.Open(fullPath)
+		if err != nil {
+			stream.Send(map[string]any{"type": "error", "error": err.Error()})
+			return
+		}
+		stat, err := f.Stat()
+		if err == nil && stat.Size() < offset {
+			offset, pending = 0, nil
+			err = stream.Send(map[string]any{"type": "truncated", "size": stat.Size()})
+		}
+		for err == nil {
+			var n int
+			n, err = f.ReadAt(buf, offset)
+			if n == 0 {
+				break
+			}
+			start := offset - int64(len(pending))
+			var data []byte
+			data, pending = fileutil.SplitUTF8(append(pending, buf[:n]...))
+			offset += int64(n)
+			event := map[string]any{
+				"type":        "data",
+				"offset":      start,
+				"next_offset": offset - int64(len(pending)),
+			}
+			addContent(event, fullPath, data, false)
+			if sendErr := stream.Send(event); sendErr != nil {
+				f.Close()
+				return
+			}
+		}
+		f.Close()
+	}
+}
@@ -10,22 +10,25 @@ import (
 	"strings"
 	"time"
 
-	"github.com/c00d-ide/c00d/internal/config"
+	"github.com/c00d-ide/c00d/internal/fileutil"
+	"github.com/c00d-ide/c00d/internal/git"
+	"github.com/c00d-ide/c00d/internal/ignore"
+	"github.com/c00d-ide/c00d/internal/language"
+	"github.com/c00d-ide/c00d/internal/workspace"
 )
 
 // Files handles directory listing requests
 func Files(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	ws := workspace.From(r)
 
 	path := r.URL.Query().Get("path")
 	if path == "" {
 		path = "/"
 	}
 
-	fullPath := filepath.Join(config.C.BasePath, path)
-
-	// Security: ensure path is within base
-	if !strings.HasPrefix(fullPath, config.C.BasePath) {
+	fullPath, ok := ws.ValidatePath(path)
+	if !ok {
 		http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
 		return
 	}
@@ -37,34 +40,72 @@ func Files(w http.ResponseWriter, r *http.Request) {
 	}
 
 	type FileInfo struct {
-		Name    string `json:"name"`
-		Path    string `json:"path"`
-		IsDir   bool   `json:"is_dir"`
-		Size    int64  `json:"size"`
-		ModTime string `json:"mod_time"`
+		Name       string `json:"name"`
+		Path       string `json:"path"`
+		IsDir      bool   `json:"is_dir"`
+		Size       int64  `json:"size"`
+		ModTime    string `json:"mod_time"`
+		Mode       string `json:"mode"` // e.g. -rwxr-xr-x
+		Perm       string `json:"perm"` // octal, e.g. 0755
+		Owner      string `json:"owner,omitempty"`
+		Group      string `json:"group,omitempty"`
+		IsSymlink  bool   `json:"is_symlink"`
+		Target     string `json:"target,omitempty"`
+		Broken     bool   `json:"broken,omitempty"`   // symlink to a missing target
+		External   bool   `json:"external,omitempty"` // symlink leaving the workspace, not followed
+		Executable bool   `json:"executable"`
+		Language   string `json:"language,omitempty"`
+		GitStatus  string `json:"git_status,omitempty"` // M, A, D, R, ?...
 	}
 
+	matcher := ignore.For(ws.Path)
+	gitStatuses := git.FileStatuses(ws.Path)
 	files := make([]FileInfo, 0)
 	for _, entry := range entries {
-		// Skip hidden files starting with .
-		if strings.HasPrefix(entry.Name(), ".") {
+		entryPath := filepath.Join(fullPath, entry.Name())
+		info, err := entry.Info()
+		if err != nil {
 			continue
 		}
-		// Skip common large/irrelevant directories
-		if entry.IsDir() && (entry.Name() == "node_modules" || entry.Name() == "vendor" || entry.Name() == ".git") {
+
+		// Symlinks are described by their target, so that linked
+		// directories can be browsed and linked files opened, unless
+		// they lead outside the workspace
+		file := FileInfo{
+			Name:      entry.Name(),
+			Path:      filepath.Join(path, entry.Name()),
+			Mode:      info.Mode().String(),
+			Perm:      fmt.Sprintf("%04o", info.Mode().Perm()),
+			IsSymlink: info.Mode()&os.ModeSymlink != 0,
+		}
+		file.Owner, file.Group = fileutil.Owner(info)
+		if file.IsSymlink {
+			file.Target, _ = os.Readlink(entryPath)
+			if !ws.ValidateFullPath(entryPath) {
+				file.External = true
+			} else if target, err := os.Stat(entryPath); err == nil {
+				info = target
+			} else {
+				file.Broken = true
+			}
+		}
+		file.IsDir = info.IsDir()
+		file.Size = info.Size()
+		file.ModTime = info.ModTime().Format(time.RFC3339)
+
+		// Skip hidden and excluded entries
+		if matcher.MatchPath(entryPath, file.IsDir) {
 			continue
 		}
 
-		info, _ := entry.Info()
-		relPath := filepath.Join(path, entry.Name())
+		if !file.IsDir {
+			// info is still the link's own when it wasn't followed
+			file.Executable = info.Mode()&os.ModeSymlink == 0 && info.Mode()&0111 != 0
+			file.Language = language.Detect(file.Name)
+		}
+		file.GitStatus = gitStatuses[strings.TrimPrefix(filepath.ToSlash(file.Path), "/")]
 
-		files = append(files, FileInfo{
-			Name:    entry.Name(),
-			Path:    relPath,
-			IsDir:   entry.IsDir(),
-			Size:    info.Size(),
-			ModTime: info.ModTime().Format(time.RFC3339),
-		})
+		files = append(files, file)
 	}
 
 	// Sort: directories first, then by name
@@ -7,12 +7,13 @@ import (
 	"os/exec"
 	"strings"
 
-	"github.com/c00d-ide/c00d/internal/config"
+	"github.com/c00d-ide/c00d/internal/workspace"
 )
 
 // Git handles git operations
 func Git(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	ws := workspace.From(r)
 
 	if r.Method != "POST" {
 		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
@@ -37,13 +38,13 @@ func Git(w http.ResponseWriter, r *http.Request) {
 
 		// Get current branch
 		cmd = exec.Command("git", "rev-parse", "--abbrev-ref", "HEAD")
-		cmd.Dir = config.C.BasePath
+		cmd.Dir = ws.Path
 		branchOut, _ := cmd.Output()
 		result["branch"] = strings.TrimSpace(string(branchOut))
 
 		// Get ahead/behind
 		cmd = exec.Command("git", "rev-list", "--left-right", "--count", "HEAD...@{upstream}")
-		cmd.Dir = config.C.BasePath
+		cmd.Dir = ws.Path
 		countOut, err := cmd.Output()
 		if err == nil {
 			parts := strings.Fields(string(countOut))
@@ -55,19 +56,19 @@ func Git(w http.ResponseWriter, r *http.Request) {
 
 		// Get staged files
 		cmd = exec.Command("git", "diff", "--cached", "--name-status")
-		cmd.Dir = config.C.BasePath
+		cmd.Dir = ws.Path
 		stagedOut, _ := cmd.Output()
 		result["staged"] = parseGitStatus(string(stagedOut))
 
 		// Get unstaged files
 		cmd = exec.Command("git", "diff", "--name-status")
-		cmd.Dir = config.C.BasePath
+		cmd.Dir = ws.Path
 		unstagedOut, _ := cmd.Output()
 		result["unstaged"] = parseGitStatus(string(unstagedOut))
 
 		// Get untracked files
 		cmd = exec.Command("git", "ls-files", "--others", "--exclude-standard")
-		cmd.Dir = config.C.BasePath
+		cmd.Dir = ws.Path
 		untrackedOut, _ := cmd.Output()
 		untracked := []string{}
 		for _, line := range strings.Split(strings.TrimSpace(string(untrackedOut)), "\n") {
@@ -135,7 +136,7 @@ func Git(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cmd.Dir = config.C.BasePath
+	cmd.Dir = ws.Path
 	cmd.Stdout = &output
 	cmd.Stderr = &output
 
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/c00d-ide/c00d/internal/db"
+	"github.com/c00d-ide/c00d/internal/diff"
+	"github.com/c00d-ide/c00d/internal/fileutil"
+	"github.com/c00d-ide/c00d/internal/history"
+	"github.com/c00d-ide/c00d/internal/workspace"
+)
+
+// History handles the local file history: timelines, diffs and restores
+func History(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ws := workspace.From(r)
+
+	if r.Method != "POST" {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Action string `json:"action"`
+		Path   string `json:"path"`
+		ID     int64  `json:"id"`
+		From   int64  `json:"from"`
+		To     int64  `json:"to"` // 0 = current file on disk
+		Limit  int    `json:"limit"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	switch req.Action {
+	case "timeline":
+		// List snapshots of one file, newest first
+		fullPath, ok := ws.ValidatePath(req.Path)
+		if !ok {
+			http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+			return
+		}
+		timeline, err := db.GetFileTimeline(ws.Path, ws.Rel(fullPath), req.Limit)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"path": req.Path, "timeline": timeline})
+
+	case "deleted":
+		// List deleted files under a directory that history can bring back
+		fullPath, ok := ws.ValidatePath(req.Path)
+		if !ok {
+			http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+			return
+		}
+		deleted, err := db.GetDeletedFiles(ws.Path, ws.Rel(fullPath), req.Limit)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"path": req.Path, "deleted": deleted})
+
+	case "content":
+		snapshot, content, ok := loadSnapshot(w, ws, req.ID)
+		if !ok {
+			return
+		}
+		result := map[string]any{"snapshot": snapshot}
+		addContent(result, snapshot.Path, content, false)
+		json.NewEncoder(w).Encode(result)
+
+	case "diff":
+		// Diff two snapshots, or a snapshot against the file as it is now
+		from, fromContent, ok := loadSnapshot(w, ws, req.From)
+		if !ok {
+			return
+		}
+		toName := from.Path + " (current)"
+		var toContent []byte
+		if req.To != 0 {
+			to, content, ok := loadSnapshot(w, ws, req.To)
+			if !ok {
+				return
+			}
+			toName = fmt.Sprintf("%s@%d", to.Path, to.ID)
+			toContent = content
+		} else {
+			fullPath, ok := ws.ValidatePath(from.Path)
+			if !ok {
+				http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+				return
+			}
+			content, err := os.ReadFile(fullPath)
+			if err != nil && !os.IsNotExist(err) {
+				http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+				return
+			}
+			toContent = content
+		}
+		if fileutil.IsBinary(fromContent) || fileutil.IsBinary(toContent) {
+			json.NewEncoder(w).Encode(map[string]any{"diff": "", "binary": true})
+			return
+		}
+		fromName := fmt.Sprintf("%s@%d", from.Path, from.ID)
+		json.NewEncoder(w).Encode(map[string]any{
+			"diff": diff.Unified(fromName, toName, string(fromContent), string(toContent), 3),
+		})
+
+	case "restore":
+		// Write a snapshot back, to its own path unless another is given
+		snapshot, content, ok := loadSnapshot(w, ws, req.ID)
+		if !ok {
+			return
+		}
+		target := req.Path
+		if target == "" {
+			target = snapshot.Path
+		}
+		fullPath, ok := ws.ValidatePath(target)
+		if !ok || ws.Protected(fullPath) {
+			http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+			return
+		}
+
+		unlock := lockFile(fullPath)
+		defer unlock()
+
+		rel := ws.Rel(fullPath)
+		snapshotBeforeWrite(ws, rel, fullPath)
+		os.MkdirAll(filepath.Dir(fullPath), 0755)
+		if err := fileutil.WriteFileAtomic(fullPath, content, 0644); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+			return
+		}
+		history.Record(ws.Path, rel, history.EventRestore, content)
+		result := map[string]any{
+			"success": true,
+			"path":    rel,
+			"version": contentVersion(content),
+		}
+		addContent(result, fullPath, content, false)
+		json.NewEncoder(w).Encode(result)
+
+	default:
+		http.Error(w, `{"error":"invalid action"}`, http.StatusBadRequest)
+	}
+}
+
+// loadSnapshot fetches a snapshot of the workspace and its content,
+// writing an error response if either is missing
+func loadSnapshot(w http.ResponseWriter, ws *workspace.Workspace, id int64) (*db.FileSnapshot, []byte, bool) {
+	snapshot, err := db.GetFileSnapshot(id)
+	if err != nil || snapshot.Root != ws.Path {
+		http.Error(w, `{"error":"snapshot not found"}`, http.StatusNotFound)
+		return nil, nil, false
+	}
+	content, err := history.Content(snapshot)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusNotFound)
+		return nil, nil, false
+	}
+	return snapshot, content, true
+}
@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/c00d-ide/c00d/internal/diff"
+	"github.com/c00d-ide/c00d/internal/fileutil"
+	"github.com/c00d-ide/c00d/internal/history"
+	"github.com/c00d-ide/c00d/internal/search"
+	"github.com/c00d-ide/c00d/internal/workspace"
+)
+
+// maxReplaceMatches caps the matches a replace preview looks for, unless
+// the request sets max_results
+const maxReplaceMatches = 10000
+
+// replaceTarget is a file selected for a replace, as previewed
+type replaceTarget struct {
+	Path    string `json:"path"`
+	Version string `json:"version"` // Version of the content the preview was made from
+	Lines   []int  `json:"lines"`   // Only change these lines, if set
+}
+
+// Replace handles project-wide search and replace. The "preview" action
+// returns the diff of every file the replacement would change, with the
+// version of its content; "apply" writes the files selected from it,
+// skipping any that changed since.
+func Replace(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ws := workspace.From(r)
+
+	if r.Method != "POST" {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		searchRequest
+		Action      string          `json:"action"`
+		Replacement string          `json:"replacement"` // $1 or ${name} stand for capture groups in regexes
+		Files       []replaceTarget `json:"files"`       // apply: the files to write
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	if req.Action != "preview" && req.Action != "apply" {
+		http.Error(w, `{"error":"invalid action"}`, http.StatusBadRequest)
+		return
+	}
+	if req.MaxResults <= 0 {
+		req.MaxResults = maxReplaceMatches
+	}
+	q, ok := req.query(w, ws)
+	if !ok {
+		return
+	}
+	literal := !req.IsRegex
+
+	if req.Action == "preview" {
+		// Find the files with matches, then work out each one's changes
+		var paths []string
+		stats := search.Run(r.Context(), q, func(matches []search.Match) {
+			paths = append(paths, matches[0].File)
+		})
+		sort.Strings(paths)
+
+		files := []map[string]any{}
+		total := 0
+		for _, rel := range paths {
+			content, err := os.ReadFile(filepath.Join(ws.Path, filepath.FromSlash(rel)))
+			if err != nil {
+				continue
+			}
+			replaced, changes := search.Replace(content, q.Pattern, req.Replacement, literal, nil)
+			if len(changes) == 0 {
+				continue
+			}
+			total += len(changes)
+			files = append(files, map[string]any{
+				"path":    rel,
+				"version": contentVersion(content),
+				"changes": changes,
+				"diff":    diff.Unified("a/"+rel, "b/"+rel, string(content), string(replaced), 3),
+			})
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"files":     files,
+			"lines":     total,
+			"truncated": stats.Truncated,
+		})
+		return
+	}
+
+	written := []map[string]any{}
+	skipped := []map[string]any{}
+	for _, f := range req.Files {
+		result, reason := applyReplace(ws, q, req.Replacement, literal, f)
+		if reason != "" {
+			skipped = append(skipped, map[string]any{"path": f.Path, "reason": reason})
+			continue
+		}
+		written = append(written, result)
+	}
+	json.NewEncoder(w).Encode(map[string]any{
+		"success": len(skipped) == 0,
+		"written": written,
+		"skipped": skipped,
+	})
+}
+
+// applyReplace writes the replacement to one previewed file, returning
+// what was written or why it wasn't. Only files the query covers, as
+// the preview does, can be written.
+func applyReplace(ws *workspace.Workspace, q search.Query, replacement string, literal bool, f replaceTarget) (map[string]any, string) {
+	fullPath, ok := ws.ValidatePath(f.Path)
+	if !ok || ws.Protected(fullPath) {
+		return nil, "access denied"
+	}
+	if !q.Covers(fullPath) {
+		return nil, "not in the search scope"
+	}
+
+	// Hold the lock so no save lands between the check and the write
+	unlock := lockFile(fullPath)
+	defer unlock()
+
+	content, err := os.ReadFile(fullPath)
+	if err != nil {
+		return nil, err.Error()
+	}
+	if f.Version == "" || contentVersion(content) != f.Version {
+		return nil, "file changed since preview"
+	}
+	if fileutil.IsBinary(content) {
+		return nil, "binary file"
+	}
+
+	var keep func(int) bool
+	if len(f.Lines) > 0 {
+		lines := make(map[int]bool, len(f.Lines))
+		for _, n := range f.Lines {
+			lines[n] = true
+		}
+		keep = func(line int) bool { return lines[line] }
+	}
+	replaced, changes := search.Replace(content, q.Pattern, replacement, literal, keep)
+	if len(changes) == 0 {
+		return nil, "nothing to replace"
+	}
+
+	rel := ws.Rel(fullPath)
+	snapshotBeforeWrite(ws, rel, fullPath)
+	if err := fileutil.WriteFileAtomic(fullPath, replaced, 0644); err != nil {
+		return nil, err.Error()
+	}
+	if err := history.Record(ws.Path, rel, history.EventReplace, replaced); err != nil {
+		log.Printf("history of %s failed: %v", fullPath, err)
+	}
+
+	lines := make([]int, len(changes))
+	for i, c := range changes {
+		lines[i] = c.Line
+	}
+	return map[string]any{
+		"path":    f.Path,
+		"version": contentVersion(replaced),
+		"lines":   lines,
+	}, ""
+}
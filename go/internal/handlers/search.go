@@ -3,20 +3,84 @@ package handlers
 import (
 	"encoding/json"
 	"fmt"
-	"io"
-	"io/fs"
 	"net/http"
-	"os"
-	"path/filepath"
-	"regexp"
 	"strings"
 
-	"github.com/c00d-ide/c00d/internal/config"
+	"github.com/c00d-ide/c00d/internal/ignore"
+	"github.com/c00d-ide/c00d/internal/search"
+	"github.com/c00d-ide/c00d/internal/workspace"
 )
 
-// Search handles file content search requests
+// maxContextLines caps the context lines sent with each match
+const maxContextLines = 50
+
+// searchRequest holds the options of a content search, shared by search
+// and replace
+type searchRequest struct {
+	Query         string   `json:"query"`
+	Path          string   `json:"path"`
+	IsRegex       bool     `json:"is_regex"`
+	CaseSensitive *bool    `json:"case_sensitive"` // Defaults to on for regexes, off otherwise
+	WholeWord     bool     `json:"whole_word"`
+	FileGlob      string   `json:"file_glob"` // Matched against file names
+	Include       []string `json:"include"`   // Globs matched against paths in the workspace
+	Exclude       []string `json:"exclude"`
+	Context       int      `json:"context"` // Lines of context above and below each match
+	ContextBefore int      `json:"context_before"`
+	ContextAfter  int      `json:"context_after"`
+	MaxResults    int      `json:"max_results"`
+}
+
+// query builds the search the request describes, writing an error
+// response and returning false if it's invalid
+func (req *searchRequest) query(w http.ResponseWriter, ws *workspace.Workspace) (search.Query, bool) {
+	if req.Query == "" {
+		http.Error(w, `{"error":"query is required"}`, http.StatusBadRequest)
+		return search.Query{}, false
+	}
+
+	searchPath, ok := ws.ValidatePath(req.Path)
+	if !ok {
+		http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+		return search.Query{}, false
+	}
+
+	caseSensitive := req.IsRegex
+	if req.CaseSensitive != nil {
+		caseSensitive = *req.CaseSensitive
+	}
+	pattern, err := search.Pattern(req.Query, req.IsRegex, caseSensitive, req.WholeWord)
+	if err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"invalid regex: %s"}`, err.Error()), http.StatusBadRequest)
+		return search.Query{}, false
+	}
+
+	before, after := req.Context, req.Context
+	if req.ContextBefore > 0 {
+		before = req.ContextBefore
+	}
+	if req.ContextAfter > 0 {
+		after = req.ContextAfter
+	}
+
+	return search.Query{
+		Root:       ws.Path,
+		Dir:        searchPath,
+		Pattern:    pattern,
+		FileGlob:   req.FileGlob,
+		Include:    ignore.ParseGlobs(req.Include),
+		Exclude:    ignore.ParseGlobs(req.Exclude),
+		Before:     min(max(before, 0), maxContextLines),
+		After:      min(max(after, 0), maxContextLines),
+		MaxResults: req.MaxResults,
+	}, true
+}
+
+// Search handles file content search requests, searching files in
+// parallel and optionally streaming matches as they are found
 func Search(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	ws := workspace.From(r)
 
 	if r.Method != "POST" {
 		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
@@ -24,122 +88,86 @@ func Search(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req struct {
-		Query      string `json:"query"`
-		Path       string `json:"path"`
-		IsRegex    bool   `json:"is_regex"`
-		MaxResults int    `json:"max_results"`
-		FileGlob   string `json:"file_glob"`
+		searchRequest
+		Stream bool `json:"stream"` // Send matches as NDJSON events as they're found
 	}
 	json.NewDecoder(r.Body).Decode(&req)
 
-	if req.Query == "" {
-		http.Error(w, `{"error":"query is required"}`, http.StatusBadRequest)
-		return
-	}
-
 	if req.MaxResults <= 0 {
 		req.MaxResults = 100
 	}
-
-	searchPath := config.C.BasePath
-	if req.Path != "" {
-		searchPath = filepath.Join(config.C.BasePath, req.Path)
-		if !strings.HasPrefix(searchPath, config.C.BasePath) {
-			http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
-			return
-		}
-	}
-
-	var pattern *regexp.Regexp
-	var err error
-	if req.IsRegex {
-		pattern, err = regexp.Compile(req.Query)
-		if err != nil {
-			http.Error(w, fmt.Sprintf(`{"error":"invalid regex: %s"}`, err.Error()), http.StatusBadRequest)
-			return
-		}
-	} else {
-		// Case-insensitive substring search
-		pattern = regexp.MustCompile("(?i)" + regexp.QuoteMeta(req.Query))
+	q, ok := req.query(w, ws)
+	if !ok {
+		return
 	}
 
-	type SearchResult struct {
-		File    string `json:"file"`
-		Line    int    `json:"line"`
-		Content string `json:"content"`
-	}
-	results := []SearchResult{}
-
-	// Skip directories
-	skipDirs := map[string]bool{
-		"node_modules": true,
-		".git":         true,
-		"vendor":       true,
-		".c00d":        true,
+	// Stream matches as they're found; the search stops if the client goes away
+	if req.Stream || strings.Contains(r.Header.Get("Accept"), "application/x-ndjson") {
+		stream := newNDJSONStream(w)
+		stats := search.Run(r.Context(), q, func(matches []search.Match) {
+			for _, m := range matches {
+				stream.Send(struct {
+					Type string `json:"type"`
+					search.Match
+				}{"match", m})
+			}
+		})
+		stream.Send(map[string]any{
+			"type":      "done",
+			"count":     stats.Count,
+			"files":     stats.Files,
+			"binary":    stats.Binary,
+			"skipped":   stats.Skipped,
+			"indexed":   stats.Indexed,
+			"truncated": stats.Truncated,
+		})
+		return
 	}
 
-	filepath.WalkDir(searchPath, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil
-		}
-
-		// Skip hidden files and directories
-		if strings.HasPrefix(d.Name(), ".") && d.Name() != "." {
-			if d.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
+	results := []search.Match{}
+	stats := search.Run(r.Context(), q, func(matches []search.Match) {
+		results = append(results, matches...)
+	})
 
-		// Skip certain directories
-		if d.IsDir() {
-			if skipDirs[d.Name()] {
-				return filepath.SkipDir
-			}
-			return nil
-		}
+	json.NewEncoder(w).Encode(map[string]any{
+		"results":   results,
+		"count":     len(results),
+		"truncated": stats.Truncated,
+	})
+}
 
-		// Check file glob if specified
-		if req.FileGlob != "" {
-			matched, _ := filepath.Match(req.FileGlob, d.Name())
-			if !matched {
-				return nil
-			}
-		}
+// SearchIndex reports the state of the workspace's search index and
+// rebuilds it on request
+func SearchIndex(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ws := workspace.From(r)
 
-		// Skip large files (> 1MB)
-		info, err := d.Info()
-		if err != nil || info.Size() > 1024*1024 {
-			return nil
+	ix := search.IndexFor(ws.Path)
+	if ix == nil {
+		if r.Method == "POST" {
+			http.Error(w, `{"error":"search index is disabled"}`, http.StatusBadRequest)
+			return
 		}
+		json.NewEncoder(w).Encode(map[string]any{"enabled": false})
+		return
+	}
 
-		// Read and search file
-		content, err := os.ReadFile(path)
-		if err != nil {
-			return nil
+	switch r.Method {
+	case "GET":
+	case "POST":
+		var req struct {
+			Action string `json:"action"`
 		}
-
-		relPath, _ := filepath.Rel(config.C.BasePath, path)
-		lines := strings.Split(string(content), "\n")
-
-		for i, line := range lines {
-			if pattern.MatchString(line) {
-				results = append(results, SearchResult{
-					File:    relPath,
-					Line:    i + 1,
-					Content: strings.TrimSpace(line),
-				})
-				if len(results) >= req.MaxResults {
-					return io.EOF
-				}
-			}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Action != "rebuild" {
+			http.Error(w, `{"error":"invalid action"}`, http.StatusBadRequest)
+			return
 		}
+		ix.StartRebuild()
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
 
-		return nil
-	})
-
-	json.NewEncoder(w).Encode(map[string]any{
-		"results": results,
-		"count":   len(results),
-	})
+	json.NewEncoder(w).Encode(map[string]any{"enabled": true, "index": ix.Status()})
 }
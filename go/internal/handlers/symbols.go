@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/c00d-ide/c00d/internal/language"
+	"github.com/c00d-ide/c00d/internal/security"
+	"github.com/c00d-ide/c00d/internal/symbols"
+	"github.com/c00d-ide/c00d/internal/workspace"
+)
+
+// Symbols is "go to symbol in workspace": it ranks the functions, types
+// and other definitions of every file against the query "q"
+func Symbols(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ws := workspace.From(r)
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	query := r.URL.Query().Get("q")
+	matches, total := symbols.For(ws.Path).Search(r.Context(), query, limit)
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"query":   query,
+		"matches": matches,
+		"total":   total,
+	})
+}
+
+// SymbolOutline returns the outline of a file: its definitions with their
+// line ranges, and with "line" set, the ones enclosing that line
+func SymbolOutline(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ws := workspace.From(r)
+
+	path := r.URL.Query().Get("path")
+	fullPath, ok := ws.ValidatePath(path)
+	if !ok {
+		http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+		return
+	}
+
+	lang := language.Detect(fullPath)
+	result := map[string]any{
+		"path":      path,
+		"language":  lang,
+		"supported": symbols.Supported(lang),
+	}
+	outline, err := symbols.For(ws.Path).File(ws.Rel(fullPath))
+	if errors.Is(err, security.ErrOutside) || errors.Is(err, security.ErrTooManyLinks) {
+		http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+		return
+	}
+	if err != nil {
+		code := http.StatusInternalServerError
+		if os.IsNotExist(err) {
+			code = http.StatusNotFound
+		}
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), code)
+		return
+	}
+	result["symbols"] = outline
+
+	if line, err := strconv.Atoi(r.URL.Query().Get("line")); err == nil {
+		result["breadcrumb"] = symbols.Enclosing(outline, line)
+	}
+	json.NewEncoder(w).Encode(result)
+}
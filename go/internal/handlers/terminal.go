@@ -3,23 +3,27 @@ package handlers
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"net/http"
-	"os/exec"
-	"path/filepath"
-	"runtime"
-	"strings"
+	"time"
 
 	"github.com/c00d-ide/c00d/internal/config"
 	"github.com/c00d-ide/c00d/internal/db"
+	"github.com/c00d-ide/c00d/internal/fileutil"
+	"github.com/c00d-ide/c00d/internal/terminal"
+	"github.com/c00d-ide/c00d/internal/workspace"
 )
 
 // Terminal handles command execution requests
 func Terminal(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	ws := workspace.From(r)
 
 	var req struct {
 		Command string `json:"command"`
 		Cwd     string `json:"cwd"`
+		Stream  bool   `json:"stream"`
+		Timeout int    `json:"timeout"` // Seconds, capped by terminal.timeout in config
 	}
 	json.NewDecoder(r.Body).Decode(&req)
 
@@ -29,37 +33,32 @@ func Terminal(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Determine working directory
-	cwd := config.C.BasePath
-	if req.Cwd != "" {
-		cwd = filepath.Join(config.C.BasePath, req.Cwd)
-		if !strings.HasPrefix(cwd, config.C.BasePath) {
-			cwd = config.C.BasePath
-		}
+	cwd, ok := ws.ValidatePath(req.Cwd)
+	if !ok {
+		cwd = ws.Path
 	}
 
 	// Save to history
-	db.DB.Exec("INSERT INTO command_history (command) VALUES (?)", req.Command)
+	historyID := db.AddCommandHistory(ws.Path, req.Command, ws.Rel(cwd))
+	start := time.Now()
 
-	// Execute command
-	var cmd *exec.Cmd
-	if runtime.GOOS == "windows" {
-		cmd = exec.Command("cmd", "/C", req.Command)
-	} else {
-		cmd = exec.Command("sh", "-c", req.Command)
+	cmd := terminal.NewCommand(req.Command, cwd, commandLimits(req.Timeout))
+
+	if req.Stream {
+		exitCode := streamCommand(w, r, cmd)
+		db.FinishCommandHistory(historyID, exitCode, time.Since(start))
+		return
 	}
-	cmd.Dir = cwd
 
+	// Execute command
 	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-
-	err := cmd.Run()
-	exitCode := 0
-	if err != nil {
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			exitCode = exitErr.ExitCode()
-		}
+	if err := cmd.Start(&stdout, &stderr); err != nil {
+		db.FinishCommandHistory(historyID, -1, time.Since(start))
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+		return
 	}
+	exitCode := cmd.Wait()
+	db.FinishCommandHistory(historyID, exitCode, time.Since(start))
 
 	output := stdout.String()
 	if stderr.Len() > 0 {
@@ -72,5 +71,117 @@ func Terminal(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]any{
 		"output":    output,
 		"exit_code": exitCode,
+		"killed":    cmd.KillReason() != "",
+		"reason":    cmd.KillReason(),
+		"truncated": cmd.Truncated(),
 	})
 }
+
+// commandLimits builds command limits from config, letting a request ask
+// for a shorter timeout than the configured one
+func commandLimits(timeout int) terminal.Limits {
+	limits := terminal.Limits{
+		MaxOutput:   config.C.Terminal.MaxOutput,
+		CPUSeconds:  config.C.Terminal.CPULimit,
+		MemoryBytes: int64(config.C.Terminal.MemoryLimit) * 1024 * 1024,
+	}
+	if max := config.C.Terminal.Timeout; max > 0 && (timeout <= 0 || timeout > max) {
+		timeout = max
+	}
+	if timeout > 0 {
+		limits.Timeout = time.Duration(timeout) * time.Second
+	}
+	return limits
+}
+
+// TerminalCancel kills a running command and its process group
+func TerminalCancel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != "POST" {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		ID string `json:"id"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+
+	if req.ID == "" {
+		http.Error(w, `{"error":"id is required"}`, http.StatusBadRequest)
+		return
+	}
+
+	if err := terminal.CancelCommand(req.ID); err != nil {
+		http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusNotFound)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"success": true})
+}
+
+// streamCommand runs cmd and streams its output as NDJSON events:
+// start (with the ID to cancel it), stdout and stderr chunks, then exit.
+// It returns the exit code.
+func streamCommand(w http.ResponseWriter, r *http.Request, cmd *terminal.Command) int {
+	stream := newNDJSONStream(w)
+	stdout := &outputEventWriter{stream: stream, label: "stdout"}
+	stderr := &outputEventWriter{stream: stream, label: "stderr"}
+
+	stream.Send(map[string]any{"type": "start", "id": cmd.ID})
+
+	if err := cmd.Start(stdout, stderr); err != nil {
+		stream.Send(map[string]any{"type": "error", "error": err.Error()})
+		return -1
+	}
+
+	// Kill the command if the client goes away
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-r.Context().Done():
+			cmd.Cancel()
+		case <-done:
+		}
+	}()
+
+	exitCode := cmd.Wait()
+	close(done)
+
+	stdout.Flush()
+	stderr.Flush()
+	stream.Send(map[string]any{
+		"type":      "exit",
+		"exit_code": exitCode,
+		"canceled":  cmd.Canceled(),
+		"killed":    cmd.KillReason() != "",
+		"reason":    cmd.KillReason(),
+		"truncated": cmd.Truncated(),
+	})
+	return exitCode
+}
+
+// outputEventWriter sends each write as a labeled output event, holding
+// back incomplete UTF-8 sequences until the rest arrives
+type outputEventWriter struct {
+	stream  *ndjsonStream
+	label   string
+	pending []byte
+}
+
+func (o *outputEventWriter) Write(p []byte) (int, error) {
+	var out []byte
+	out, o.pending = fileutil.SplitUTF8(append(o.pending, p...))
+	if len(out) > 0 {
+		o.stream.Send(map[string]any{"type": o.label, "data": string(out)})
+	}
+	return len(p), nil
+}
+
+// Flush sends any held-back bytes
+func (o *outputEventWriter) Flush() {
+	if len(o.pending) > 0 {
+		o.stream.Send(map[string]any{"type": o.label, "data": string(o.pending)})
+		o.pending = nil
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/c00d-ide/c00d/internal/db"
+	"github.com/c00d-ide/c00d/internal/workspace"
+)
+
+// TerminalHistory handles command history search requests
+func TerminalHistory(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ws := workspace.From(r)
+
+	if r.Method != "GET" {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	q := r.URL.Query()
+	query := db.HistoryQuery{
+		Root:   ws.Path,
+		Unique: q.Get("unique") != "",
+		Limit:  50,
+	}
+	if l, err := strconv.Atoi(q.Get("limit")); err == nil && l > 0 {
+		query.Limit = min(l, 500)
+	}
+	if o, err := strconv.Atoi(q.Get("offset")); err == nil && o > 0 {
+		query.Offset = o
+	}
+
+	if search := q.Get("q"); search != "" {
+		if q.Get("regex") != "" {
+			pattern, err := regexp.Compile(search)
+			if err != nil {
+				http.Error(w, fmt.Sprintf(`{"error":"invalid regex: %s"}`, err.Error()), http.StatusBadRequest)
+				return
+			}
+			query.Regex = pattern
+		} else {
+			query.Search = search
+		}
+	}
+
+	if cwd := q.Get("cwd"); cwd != "" {
+		query.Cwd = ws.Rel(filepath.Join(ws.Path, cwd))
+	}
+
+	entries, err := db.GetCommandHistory(query)
+	if err != nil {
+		http.Error(w, `{"error":"failed to fetch history"}`, http.StatusInternalServerError)
+		return
+	}
+
+	hasMore := len(entries) > query.Limit
+	if hasMore {
+		entries = entries[:query.Limit]
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"history":  entries,
+		"count":    len(entries),
+		"offset":   query.Offset,
+		"has_more": hasMore,
+	})
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/c00d-ide/c00d/internal/db"
+	"github.com/c00d-ide/c00d/internal/terminal"
+	"github.com/c00d-ide/c00d/internal/workspace"
+)
+
+// TerminalSessions handles listing, creating and killing persistent terminal sessions
+func TerminalSessions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ws := workspace.From(r)
+
+	switch r.Method {
+	case "GET":
+		// List sessions, with live client counts for running ones
+		includeClosed := r.URL.Query().Get("all") != ""
+		stored, err := db.GetTerminalSessions(includeClosed, 50)
+		if err != nil {
+			http.Error(w, `{"error":"failed to fetch sessions"}`, http.StatusInternalServerError)
+			return
+		}
+
+		result := make([]map[string]any, 0, len(stored))
+		for _, s := range stored {
+			entry := map[string]any{
+				"id":            s.ID,
+				"name":          s.Name,
+				"cwd":           s.Cwd,
+				"pid":           s.PID,
+				"status":        s.Status,
+				"exit_code":     s.ExitCode,
+				"created_at":    s.CreatedAt,
+				"last_attached": s.LastAttached,
+				"closed_at":     s.ClosedAt,
+				"clients":       0,
+			}
+			if live, err := terminal.Get(s.ID); err == nil {
+				entry["clients"] = live.Clients()
+			}
+			result = append(result, entry)
+		}
+
+		json.NewEncoder(w).Encode(map[string]any{
+			"sessions": result,
+			"count":    len(result),
+		})
+
+	case "POST":
+		// Start a new session without attaching to it
+		var req struct {
+			Name string `json:"name"`
+			Cwd  string `json:"cwd"`
+			Cols uint16 `json:"cols"`
+			Rows uint16 `json:"rows"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		cwd := ws.Path
+		if req.Cwd != "" {
+			fullPath, ok := ws.ValidatePath(req.Cwd)
+			if !ok {
+				http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+				return
+			}
+			cwd = fullPath
+		}
+		if req.Cols == 0 || req.Rows == 0 {
+			req.Cols, req.Rows = 80, 24
+		}
+
+		sess, err := terminal.Create(req.Name, cwd, req.Cols, req.Rows)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"success": true, "session": sess})
+
+	case "DELETE":
+		// Kill a session
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			http.Error(w, `{"error":"id is required"}`, http.StatusBadRequest)
+			return
+		}
+		if err := terminal.Kill(id); err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"success": true})
+
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
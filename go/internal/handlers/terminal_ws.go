@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/c00d-ide/c00d/internal/terminal"
+	"github.com/c00d-ide/c00d/internal/workspace"
+)
+
+// terminalUpgrader upgrades terminal requests to WebSocket connections.
+// The default origin check is kept so other sites can't drive a shell
+// with the user's session cookie.
+var terminalUpgrader = websocket.Upgrader{
+	ReadBufferSize:  4096,
+	WriteBufferSize: 4096,
+}
+
+// terminalMessage is a message exchanged over the terminal WebSocket.
+// It uses the same protocol as the Node terminal server.
+type terminalMessage struct {
+	Type     string `json:"type"` // input, resize, ping, detach, kill (client); session, output, exit, pong, error (server)
+	Data     string `json:"data,omitempty"`
+	Cols     uint16 `json:"cols,omitempty"`
+	Rows     uint16 `json:"rows,omitempty"`
+	ExitCode *int   `json:"exitCode,omitempty"`
+	Signal   int    `json:"signal,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name,omitempty"`
+}
+
+// TerminalWS serves an interactive PTY shell over a WebSocket connection.
+// With ?session=ID it reattaches to a running session and replays its
+// scrollback; otherwise it starts a new session.
+func TerminalWS(w http.ResponseWriter, r *http.Request) {
+	ws := workspace.From(r)
+
+	q := r.URL.Query()
+
+	var sess *terminal.Session
+	if id := q.Get("session"); id != "" {
+		var err error
+		sess, err = terminal.Get(id)
+		if err != nil {
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), http.StatusNotFound)
+			return
+		}
+	}
+
+	cwd := ws.Path
+	if c := q.Get("cwd"); c != "" {
+		fullPath, ok := ws.ValidatePath(c)
+		if !ok {
+			http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+			return
+		}
+		cwd = fullPath
+	}
+
+	var cols, rows uint16
+	if n, err := strconv.Atoi(q.Get("cols")); err == nil && n > 0 {
+		cols = uint16(n)
+	}
+	if n, err := strconv.Atoi(q.Get("rows")); err == nil && n > 0 {
+		rows = uint16(n)
+	}
+
+	conn, err := terminalUpgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written an error response
+		return
+	}
+	defer conn.Close()
+
+	// gorilla/websocket allows only one concurrent writer
+	var writeMu sync.Mutex
+	send := func(msg terminalMessage) error {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		return conn.WriteJSON(msg)
+	}
+	closeWith := func(code int, reason string) {
+		writeMu.Lock()
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(code, reason),
+			time.Now().Add(time.Second))
+		writeMu.Unlock()
+		conn.Close()
+	}
+
+	if sess == nil {
+		if cols == 0 || rows == 0 {
+			cols, rows = 80, 24
+		}
+		sess, err = terminal.Create(q.Get("name"), cwd, cols, rows)
+		if err != nil {
+			send(terminalMessage{Type: "error", Data: err.Error()})
+			return
+		}
+	} else if cols > 0 && rows > 0 {
+		sess.Resize(cols, rows)
+	}
+
+	send(terminalMessage{Type: "session", ID: sess.ID, Name: sess.Name})
+
+	replay, output, detach := sess.Attach()
+	defer detach()
+	if len(replay) > 0 {
+		send(terminalMessage{Type: "output", Data: string(replay)})
+	}
+
+	// Forward session output to the WebSocket
+	go func() {
+		for data := range output {
+			send(terminalMessage{Type: "output", Data: string(data)})
+		}
+		select {
+		case <-sess.Done():
+			exitCode := sess.ExitCode()
+			send(terminalMessage{Type: "exit", ExitCode: &exitCode, Signal: sess.Signal()})
+			closeWith(websocket.CloseNormalClosure, "shell exited")
+		default:
+			// Detached, or dropped for falling behind
+			closeWith(websocket.CloseNormalClosure, "detached")
+		}
+	}()
+
+	// Forward client messages to the session
+	for {
+		var msg terminalMessage
+		if err := conn.ReadJSON(&msg); err != nil {
+			break
+		}
+		switch msg.Type {
+		case "input":
+			sess.Write([]byte(msg.Data))
+		case "resize":
+			if msg.Cols > 0 && msg.Rows > 0 {
+				sess.Resize(msg.Cols, msg.Rows)
+			}
+		case "ping":
+			send(terminalMessage{Type: "pong"})
+		case "detach":
+			detach()
+		case "kill":
+			sess.Kill()
+		}
+	}
+	// The session keeps running after the client goes away
+}
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/c00d-ide/c00d/internal/db"
+	"github.com/c00d-ide/c00d/internal/trash"
+	"github.com/c00d-ide/c00d/internal/workspace"
+)
+
+// Trash handles listing, restoring and purging deleted files
+func Trash(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ws := workspace.From(r)
+
+	switch r.Method {
+	case "GET":
+		// List trashed items, newest first
+		items, err := db.GetTrashItems(ws.Path, time.Time{})
+		if err != nil {
+			http.Error(w, `{"error":"failed to fetch trash"}`, http.StatusInternalServerError)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"items": items,
+			"count": len(items),
+		})
+
+	case "POST":
+		// Restore an item to its original path, or to "path" if given
+		var req struct {
+			ID   string `json:"id"`
+			Path string `json:"path"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		item, err := db.GetTrashItem(req.ID)
+		if err != nil || item.Root != ws.Path {
+			http.Error(w, `{"error":"trash item not found"}`, http.StatusNotFound)
+			return
+		}
+		target := req.Path
+		if target == "" {
+			target = item.OriginalPath
+		}
+		fullPath, ok := ws.ValidatePath(target)
+		if !ok || ws.Protected(fullPath) {
+			http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+			return
+		}
+
+		if err := trash.Restore(item.ID, fullPath); err != nil {
+			code := http.StatusInternalServerError
+			if err == trash.ErrExists {
+				code = http.StatusConflict
+			}
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), code)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"success": true, "path": ws.Rel(fullPath)})
+
+	case "DELETE":
+		// Purge one item, or empty the whole trash with all=1. Emptying
+		// must be asked for, so a request that lost its id purges nothing.
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			if r.URL.Query().Get("all") != "1" {
+				http.Error(w, `{"error":"id or all=1 is required"}`, http.StatusBadRequest)
+				return
+			}
+			purged := trash.PurgeOlderThan(ws.Path, time.Time{})
+			json.NewEncoder(w).Encode(map[string]any{"success": true, "purged": purged})
+			return
+		}
+		if item, err := db.GetTrashItem(id); err != nil || item.Root != ws.Path {
+			http.Error(w, `{"error":"trash item not found"}`, http.StatusNotFound)
+			return
+		}
+		if err := trash.Purge(id); err != nil {
+			code := http.StatusInternalServerError
+			if err == trash.ErrNotFound {
+				code = http.StatusNotFound
+			}
+			http.Error(w, fmt.Sprintf(`{"error":"%s"}`, err.Error()), code)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"success": true, "purged": 1})
+
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
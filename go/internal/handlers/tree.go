@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/c00d-ide/c00d/internal/db"
+	"github.com/c00d-ide/c00d/internal/filetree"
+	"github.com/c00d-ide/c00d/internal/fuzzy"
+	"github.com/c00d-ide/c00d/internal/security"
+	"github.com/c00d-ide/c00d/internal/workspace"
+)
+
+// Tree returns every file in the workspace, or under "path", as a flat
+// list of relative paths. It honours .gitignore inside git repositories.
+func Tree(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ws := workspace.From(r)
+
+	fullPath, ok := ws.ValidatePath(r.URL.Query().Get("path"))
+	if !ok {
+		http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+		return
+	}
+
+	tree := filetree.For(ws.Path)
+	if r.URL.Query().Get("refresh") != "" {
+		tree.Rebuild()
+	}
+	files := filetree.Under(tree.Files(), ws.Rel(fullPath))
+	source, builtAt := tree.Info()
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"path":     r.URL.Query().Get("path"),
+		"files":    files,
+		"count":    len(files),
+		"source":   source,
+		"built_at": builtAt.Format(time.RFC3339),
+	})
+}
+
+// Find is the "go to file" fuzzy finder: it ranks every file path against
+// the query "q", favouring recently opened files
+func Find(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ws := workspace.From(r)
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+
+	files := filetree.For(ws.Path).Files()
+	matches := fuzzy.Find(r.URL.Query().Get("q"), files, recentFiles(ws), limit)
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"query":   r.URL.Query().Get("q"),
+		"matches": matches,
+		"total":   len(files),
+	})
+}
+
+// recentFiles returns the recently opened files of the workspace as
+// relative paths
+func recentFiles(ws *workspace.Workspace) []string {
+	recent := []string{}
+	for _, p := range db.GetRecentFiles(50) {
+		if filepath.IsAbs(p) && security.WithinDir(ws.Path, p) {
+			recent = append(recent, ws.Rel(p))
+		}
+	}
+	return recent
+}
@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/c00d-ide/c00d/internal/config"
+	"github.com/c00d-ide/c00d/internal/fileutil"
+	"github.com/c00d-ide/c00d/internal/history"
+	"github.com/c00d-ide/c00d/internal/workspace"
+)
+
+// Upload handles multipart uploads of one or more files into a directory.
+// Each part's filename may be a relative path (as sent for folder uploads),
+// which is recreated under the target directory.
+func Upload(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	ws := workspace.From(r)
+
+	if r.Method != "POST" {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	dir, ok := ws.ValidatePath(r.URL.Query().Get("path"))
+	if !ok || ws.Protected(dir) {
+		http.Error(w, `{"error":"access denied"}`, http.StatusForbidden)
+		return
+	}
+	overwrite := r.URL.Query().Get("overwrite") != ""
+
+	if limit := config.C.Files.MaxUpload; limit > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, `{"error":"expected multipart/form-data"}`, http.StatusBadRequest)
+		return
+	}
+
+	uploaded := []map[string]any{}
+	skipped := []map[string]any{}
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			uploadError(w, err, uploaded, skipped)
+			return
+		}
+
+		name := uploadName(part.Header.Get("Content-Disposition"))
+		if name == "" {
+			part.Close()
+			continue // Not a file field
+		}
+
+		fullPath, ok := ws.ValidatePath(filepath.Join(ws.Rel(dir), filepath.FromSlash(name)))
+		if !ok || ws.Protected(fullPath) {
+			part.Close()
+			skipped = append(skipped, map[string]any{"path": name, "error": "access denied"})
+			continue
+		}
+
+		size, err := saveUpload(ws, fullPath, part, overwrite)
+		part.Close()
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				uploadError(w, err, uploaded, skipped)
+				return
+			}
+			skipped = append(skipped, map[string]any{"path": ws.Rel(fullPath), "error": err.Error()})
+			continue
+		}
+		uploaded = append(uploaded, map[string]any{"path": ws.Rel(fullPath), "size": size})
+	}
+
+	json.NewEncoder(w).Encode(map[string]any{
+		"success":  len(skipped) == 0,
+		"uploaded": uploaded,
+		"skipped":  skipped,
+	})
+}
+
+// saveUpload streams one uploaded file to fullPath
+func saveUpload(ws *workspace.Workspace, fullPath string, content io.Reader, overwrite bool) (int64, error) {
+	unlock := lockFile(fullPath)
+	defer unlock()
+
+	rel := ws.Rel(fullPath)
+	if info, err := os.Stat(fullPath); err == nil {
+		if !overwrite || info.IsDir() {
+			return 0, errors.New("file already exists")
+		}
+		snapshotBeforeWrite(ws, rel, fullPath)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return 0, err
+	}
+	size, err := fileutil.WriteReaderAtomic(fullPath, content, 0644)
+	if err != nil {
+		return 0, err
+	}
+	if err := history.RecordFile(ws.Path, rel, history.EventUpload, fullPath); err != nil {
+		log.Printf("history of %s failed: %v", fullPath, err)
+	}
+	return size, nil
+}
+
+// uploadName returns the cleaned relative path a part was uploaded as, or
+// "" for non-file fields. multipart.Part.FileName drops directories, so the
+// header is parsed directly.
+func uploadName(disposition string) string {
+	_, params, err := mime.ParseMediaType(disposition)
+	if err != nil || params["filename"] == "" {
+		return ""
+	}
+	name := path.Clean("/" + strings.ReplaceAll(params["filename"], `\`, "/"))
+	if name == "/" {
+		return ""
+	}
+	return strings.TrimPrefix(name, "/")
+}
+
+// uploadError reports a failure reading the request body, with the files
+// already saved from it and those skipped before it
+func uploadError(w http.ResponseWriter, err error, uploaded, skipped []map[string]any) {
+	code := http.StatusBadRequest
+	message := err.Error()
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		code = http.StatusRequestEntityTooLarge
+		message = fmt.Sprintf("upload exceeds the %d byte limit", maxErr.Limit)
+	}
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error":    message,
+		"success":  false,
+		"uploaded": uploaded,
+		"skipped":  skipped,
+	})
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/c00d-ide/c00d/internal/watcher"
+	"github.com/c00d-ide/c00d/internal/workspace"
+)
+
+// Watch streams file change events in the workspace as Server-Sent Events
+func Watch(w http.ResponseWriter, r *http.Request) {
+	ws := workspace.From(r)
+
+	if r.Method != "GET" {
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Optional subdirectory to limit events to
+	prefix := strings.Trim(path.Clean("/"+r.URL.Query().Get("path")), "/")
+
+	wt := watcher.For(ws.Path)
+	events, unsubscribe := wt.Subscribe()
+	defer unsubscribe()
+
+	stream := newSSEStream(w)
+	stream.Send("ready", map[string]any{"mode": wt.Mode})
+
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+
+		case batch, ok := <-events:
+			if !ok {
+				return
+			}
+			if prefix != "" {
+				filtered := []watcher.Event{}
+				for _, ev := range batch {
+					if ev.Type == watcher.Overflow || underPath(ev.Path, prefix) || underPath(ev.OldPath, prefix) {
+						filtered = append(filtered, ev)
+					}
+				}
+				batch = filtered
+			}
+			if len(batch) > 0 {
+				stream.Send("change", batch)
+			}
+
+		case <-heartbeat.C:
+			stream.Ping()
+		}
+	}
+}
+
+// underPath reports whether slash-separated path p is dir or inside it
+func underPath(p, dir string) bool {
+	return p == dir || strings.HasPrefix(p, dir+"/")
+}
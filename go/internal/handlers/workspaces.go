@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/c00d-ide/c00d/internal/workspace"
+)
+
+// Workspaces lists the configured workspaces and switches the one the
+// editor works in, which is remembered in a cookie
+func Workspaces(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case "GET":
+		json.NewEncoder(w).Encode(map[string]any{
+			"workspaces": workspace.List(),
+			"current":    workspace.From(r).ID,
+		})
+
+	case "POST":
+		var req struct {
+			ID string `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		ws := workspace.Get(req.ID)
+		if ws == nil {
+			http.Error(w, `{"error":"unknown workspace"}`, http.StatusNotFound)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     workspace.CookieName,
+			Value:    ws.ID,
+			Path:     "/",
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+		json.NewEncoder(w).Encode(map[string]any{"success": true, "workspace": ws})
+
+	default:
+		http.Error(w, `{"error":"method not allowed"}`, http.StatusMethodNotAllowed)
+	}
+}
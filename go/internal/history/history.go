@@ -0,0 +1,226 @@
+package history
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c00d-ide/c00d/internal/config"
+	"github.com/c00d-ide/c00d/internal/db"
+	"github.com/c00d-ide/c00d/internal/fileutil"
+	"github.com/c00d-ide/c00d/internal/ignore"
+)
+
+// Timeline events
+const (
+	EventOriginal = "original" // Content found on disk before the first save
+	EventExternal = "external" // Content changed outside the IDE since the last snapshot
+	EventSave     = "save"
+	EventDelete   = "delete"
+	EventRename   = "rename"
+	EventRestore  = "restore"
+	EventUpload   = "upload"
+	EventReplace  = "replace"
+)
+
+// ErrNotFound is returned when a snapshot or its content doesn't exist
+var ErrNotFound = errors.New("snapshot not found")
+
+// blobDir holds snapshot contents, stored once per distinct content
+func blobDir() string {
+	return filepath.Join(config.C.DataDir, "history", "blobs")
+}
+
+func blobPath(hash string) string {
+	return filepath.Join(blobDir(), hash[:2], hash)
+}
+
+func hashOf(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// blobsMu keeps cleanup from removing a blob between a snapshot storing
+// it and the snapshot's row being added. Snapshots share it; cleanup holds
+// it alone.
+var blobsMu sync.RWMutex
+
+// store writes content to the blob store unless it's already there
+func store(content []byte) (string, error) {
+	hash := hashOf(content)
+	p := blobPath(hash)
+	if _, err := os.Stat(p); err == nil {
+		return hash, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return "", err
+	}
+	return hash, fileutil.WriteFileAtomic(p, content, 0600)
+}
+
+// Record adds a snapshot of content to the timeline of relPath, a path
+// relative to the workspace root
+func Record(root, relPath, event string, content []byte) error {
+	if !config.ShouldKeepHistory() || int64(len(content)) > config.C.Files.History.MaxFileSize {
+		return nil
+	}
+	return add(root, relPath, event, content)
+}
+
+// add stores content and adds its snapshot to the timeline of relPath
+func add(root, relPath, event string, content []byte) error {
+	blobsMu.RLock()
+	defer blobsMu.RUnlock()
+	hash, err := store(content)
+	if err != nil {
+		return err
+	}
+	return db.AddFileSnapshot(root, relPath, event, hash, int64(len(content)), "")
+}
+
+// RecordFile adds a snapshot of the file at fullPath to the timeline of relPath
+func RecordFile(root, relPath, event, fullPath string) error {
+	if !config.ShouldKeepHistory() {
+		return nil
+	}
+	content, err := readSnapshot(fullPath)
+	if err != nil || content == nil {
+		return err
+	}
+	return Record(root, relPath, event, content)
+}
+
+// BeforeWrite snapshots the current content of fullPath if history doesn't
+// have it yet, so the version being overwritten can always be restored
+func BeforeWrite(root, relPath, fullPath string) error {
+	if !config.ShouldKeepHistory() {
+		return nil
+	}
+	content, err := readSnapshot(fullPath)
+	if err != nil || content == nil {
+		return err
+	}
+
+	latest := db.LatestFileSnapshot(root, relPath)
+	switch {
+	case latest == nil:
+		return Record(root, relPath, EventOriginal, content)
+	case latest.Hash != hashOf(content):
+		return Record(root, relPath, EventExternal, content)
+	}
+	return nil
+}
+
+// RecordDelete snapshots fullPath, or every file under it when it's a
+// directory, before it is deleted
+func RecordDelete(root, relPath, fullPath string) error {
+	if !config.ShouldKeepHistory() {
+		return nil
+	}
+	matcher := ignore.For(root)
+	return filepath.WalkDir(fullPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() {
+			if p != fullPath && matcher.MatchPath(p, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel := relPath
+		if p != fullPath {
+			sub, _ := filepath.Rel(fullPath, p)
+			rel = path.Join(relPath, filepath.ToSlash(sub))
+		}
+		content, err := readSnapshot(p)
+		if err != nil || content == nil {
+			// Too large to keep; still mark it deleted
+			return db.AddFileSnapshot(root, rel, EventDelete, "", 0, "")
+		}
+		return add(root, rel, EventDelete, content)
+	})
+}
+
+// RecordRename moves the timeline of oldRel, and of everything under it,
+// to newRel and notes the rename
+func RecordRename(root, oldRel, newRel string) error {
+	if !config.ShouldKeepHistory() {
+		return nil
+	}
+	db.RenameFileHistory(root, oldRel, newRel)
+	return db.AddFileSnapshot(root, newRel, EventRename, "", 0, oldRel)
+}
+
+// Content returns the content stored by a snapshot
+func Content(snapshot *db.FileSnapshot) ([]byte, error) {
+	if snapshot.Hash == "" {
+		return nil, ErrNotFound
+	}
+	content, err := os.ReadFile(blobPath(snapshot.Hash))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return content, err
+}
+
+// readSnapshot reads fullPath if it's a regular file small enough to keep.
+// It returns nil content when there is nothing to snapshot.
+func readSnapshot(fullPath string) ([]byte, error) {
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if !info.Mode().IsRegular() || info.Size() > config.C.Files.History.MaxFileSize {
+		return nil, nil
+	}
+	return os.ReadFile(fullPath)
+}
+
+// StartCleanupRoutine applies the retention policy and removes unreferenced
+// content every hour
+func StartCleanupRoutine() {
+	go func() {
+		for {
+			cleanup()
+			time.Sleep(1 * time.Hour)
+		}
+	}()
+}
+
+func cleanup() {
+	db.PruneFileHistory(config.C.Files.History.MaxVersions, config.C.Files.History.MaxDays)
+
+	blobsMu.Lock()
+	defer blobsMu.Unlock()
+	hashes, err := db.FileHistoryHashes()
+	if err != nil {
+		log.Printf("file history cleanup failed: %v", err)
+		return
+	}
+	filepath.WalkDir(blobDir(), func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return nil
+		}
+		// Leave temp files of writes in progress alone
+		if name := d.Name(); len(name) == 64 && !strings.HasPrefix(name, ".") && !hashes[name] {
+			os.Remove(p)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,231 @@
+package ignore
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c00d-ide/c00d/internal/config"
+	"github.com/c00d-ide/c00d/internal/workspace"
+)
+
+// ignoreFiles are read in every directory when files.gitignore is on
+var ignoreFiles = []string{".gitignore", ".ignore"}
+
+// recheckDelay is how long a directory's ignore files are trusted before
+// they are checked for changes
+const recheckDelay = 2 * time.Second
+
+// Matcher decides which paths under a root are hidden from listings,
+// search, watching and indexing. Rules are, in order: data directories
+// are always hidden, dotfiles unless files.show_hidden is set, files.exclude
+// patterns, then .gitignore and .ignore files if files.gitignore is set.
+// Workspaces can override each of these settings.
+type Matcher struct {
+	Root string
+
+	mu      sync.Mutex
+	dirs    map[string]*dirRules // Keyed by slash-separated dir relative to Root
+	exclude []rule
+	source  string // files.exclude the rules were compiled from
+}
+
+// dirRules caches the ignore files of one directory
+type dirRules struct {
+	rules   []rule
+	stamp   string // Sizes and mod times of the files the rules came from
+	checked time.Time
+}
+
+var (
+	matchersMu sync.Mutex
+	matchers   = map[string]*Matcher{}
+)
+
+// For returns the matcher for root
+func For(root string) *Matcher {
+	matchersMu.Lock()
+	defer matchersMu.Unlock()
+
+	if m, ok := matchers[root]; ok {
+		return m
+	}
+	m := &Matcher{Root: root, dirs: map[string]*dirRules{}}
+	matchers[root] = m
+	return m
+}
+
+// Match reports whether one entry is hidden, given that its parent
+// directory isn't. rel is slash-separated and relative to the root. Tree
+// walkers that skip hidden directories use this.
+func (m *Matcher) Match(rel string, isDir bool) bool {
+	rel = strings.Trim(rel, "/")
+	if rel == "" || rel == "." {
+		return false
+	}
+	if m.isDataDir(rel) {
+		return true
+	}
+	if !m.settings().ShowHidden && strings.HasPrefix(path.Base(rel), ".") {
+		return true
+	}
+	if _, ignored := match(m.excludeRules(), rel, isDir); ignored {
+		return true
+	}
+	if !m.Gitignore() {
+		return false
+	}
+
+	// Rules from the root down to the parent; deeper files override
+	ignored := false
+	if _, excluded := match(m.rulesFor(".git/info"), rel, isDir); excluded {
+		ignored = true
+	}
+	dir := ""
+	for {
+		sub := rel
+		if dir != "" {
+			sub = strings.TrimPrefix(rel, dir+"/")
+		}
+		if matched, excluded := match(m.rulesFor(dir), sub, isDir); matched {
+			ignored = excluded
+		}
+		next := strings.IndexByte(sub, '/')
+		if next < 0 {
+			break
+		}
+		dir = path.Join(dir, sub[:next])
+	}
+	return ignored
+}
+
+// Ignored reports whether rel, or any directory above it, is hidden
+func (m *Matcher) Ignored(rel string, isDir bool) bool {
+	rel = strings.Trim(rel, "/")
+	parts := strings.Split(rel, "/")
+	for i := 1; i < len(parts); i++ {
+		if m.Match(strings.Join(parts[:i], "/"), true) {
+			return true
+		}
+	}
+	return m.Match(rel, isDir)
+}
+
+// MatchPath is Match for an absolute path. Paths outside the root are
+// never hidden.
+func (m *Matcher) MatchPath(fullPath string, isDir bool) bool {
+	rel, err := filepath.Rel(m.Root, fullPath)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	return m.Match(filepath.ToSlash(rel), isDir)
+}
+
+// Filter returns the files in paths that aren't hidden, checking each
+// directory only once
+func (m *Matcher) Filter(paths []string) []string {
+	dirs := map[string]bool{}
+	var dirIgnored func(dir string) bool
+	dirIgnored = func(dir string) bool {
+		if dir == "." || dir == "" {
+			return false
+		}
+		if ignored, ok := dirs[dir]; ok {
+			return ignored
+		}
+		ignored := dirIgnored(path.Dir(dir)) || m.Match(dir, true)
+		dirs[dir] = ignored
+		return ignored
+	}
+
+	kept := paths[:0:0]
+	for _, p := range paths {
+		if !dirIgnored(path.Dir(p)) && !m.Match(p, false) {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
+
+// settings returns the visibility settings of the workspace at the root,
+// or the global ones if the root isn't a workspace
+func (m *Matcher) settings() *workspace.Workspace {
+	if ws := workspace.ForRoot(m.Root); ws != nil {
+		return ws
+	}
+	return &workspace.Workspace{
+		DataDir:    config.C.DataDir,
+		ShowHidden: config.C.Files.ShowHidden,
+		Exclude:    config.C.Files.Exclude,
+		Gitignore:  config.C.Files.Gitignore,
+	}
+}
+
+// Gitignore reports whether .gitignore and .ignore files apply
+func (m *Matcher) Gitignore() bool {
+	return m.settings().Gitignore
+}
+
+// isDataDir reports whether rel is the server's or the workspace's data
+// directory
+func (m *Matcher) isDataDir(rel string) bool {
+	for _, dir := range []string{config.C.DataDir, m.settings().DataDir} {
+		if dataRel, err := filepath.Rel(m.Root, dir); err == nil && filepath.ToSlash(dataRel) == rel {
+			return true
+		}
+	}
+	return false
+}
+
+// excludeRules returns the compiled files.exclude patterns
+func (m *Matcher) excludeRules() []rule {
+	source := strings.Join(m.settings().Exclude, "\n")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.exclude == nil || m.source != source {
+		m.exclude = parseRules(source)
+		m.source = source
+	}
+	return m.exclude
+}
+
+// rulesFor returns the rules of the ignore files in dir, reloading them
+// when they change. .git/info holds the repository's exclude file.
+func (m *Matcher) rulesFor(dir string) []rule {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cached := m.dirs[dir]
+	if cached != nil && time.Since(cached.checked) < recheckDelay {
+		return cached.rules
+	}
+
+	names := ignoreFiles
+	if dir == ".git/info" {
+		names = []string{"exclude"}
+	}
+	var stamp strings.Builder
+	for _, name := range names {
+		if info, err := os.Stat(filepath.Join(m.Root, filepath.FromSlash(dir), name)); err == nil {
+			fmt.Fprintf(&stamp, "%s %d %d;", name, info.ModTime().UnixNano(), info.Size())
+		}
+	}
+	if cached != nil && cached.stamp == stamp.String() {
+		cached.checked = time.Now()
+		return cached.rules
+	}
+
+	var rules []rule
+	for _, name := range names {
+		if content, err := os.ReadFile(filepath.Join(m.Root, filepath.FromSlash(dir), name)); err == nil {
+			rules = append(rules, parseRules(string(content))...)
+		}
+	}
+	m.dirs[dir] = &dirRules{rules: rules, stamp: stamp.String(), checked: time.Now()}
+	return rules
+}
@@ -0,0 +1,155 @@
+package ignore
+
+import (
+	"regexp"
+	"strings"
+)
+
+// rule is one line of a .gitignore-style file
+type rule struct {
+	re      *regexp.Regexp
+	negate  bool // "!pattern" re-includes what earlier rules excluded
+	dirOnly bool // "pattern/" only matches directories
+}
+
+// parseRules parses gitignore syntax, one pattern per line
+func parseRules(text string) []rule {
+	var rules []rule
+	for _, line := range strings.Split(text, "\n") {
+		if r, ok := parseRule(line); ok {
+			rules = append(rules, r)
+		}
+	}
+	return rules
+}
+
+// parseRule parses one gitignore pattern. Blank lines and comments yield
+// no rule.
+func parseRule(line string) (rule, bool) {
+	line = strings.TrimSuffix(line, "\r")
+	// Trailing spaces are ignored unless escaped
+	for strings.HasSuffix(line, " ") && !strings.HasSuffix(line, `\ `) {
+		line = line[:len(line)-1]
+	}
+	if line == "" || strings.HasPrefix(line, "#") {
+		return rule{}, false
+	}
+
+	var r rule
+	if strings.HasPrefix(line, "!") {
+		r.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		r.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return rule{}, false
+	}
+
+	// A slash anywhere but the end anchors the pattern to the file's
+	// directory; otherwise it matches at any depth
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(?:.*/)?" + expr
+	}
+	re, err := regexp.Compile("^" + expr + "$")
+	if err != nil {
+		return rule{}, false
+	}
+	r.re = re
+	return r, true
+}
+
+// globToRegexp translates a gitignore glob: * and ? don't cross slashes,
+// ** does, and [...] is a character class
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/") && (i == 0 || glob[i-1] == '/'):
+			b.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**") && i+2 == len(glob) && (i == 0 || glob[i-1] == '/'):
+			b.WriteString(".*")
+			i++
+		case c == '*':
+			b.WriteString("[^/]*")
+		case c == '?':
+			b.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				b.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			b.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return b.String()
+}
+
+// match applies rules in order to a path relative to their directory; the
+// last rule that matches decides. It returns whether any rule matched and
+// whether the path is ignored.
+func match(rules []rule, rel string, isDir bool) (matched, ignored bool) {
+	for _, r := range rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		if r.re.MatchString(rel) {
+			matched, ignored = true, !r.negate
+		}
+	}
+	return matched, ignored
+}
+
+// Globs are gitignore-style patterns for picking files by their path
+// relative to a root. A pattern without a slash matches a name at any
+// depth, one with a slash is anchored to the root, and ** spans
+// directories. A path matches if it or a directory above it does.
+type Globs []rule
+
+// ParseGlobs parses patterns, skipping empty ones
+func ParseGlobs(patterns []string) Globs {
+	var globs Globs
+	for _, p := range patterns {
+		if r, ok := parseRule(p); ok {
+			globs = append(globs, r)
+		}
+	}
+	return globs
+}
+
+// Match reports whether rel, slash-separated, matches the globs
+func (g Globs) Match(rel string) bool {
+	if len(g) == 0 {
+		return false
+	}
+	for i := 0; i < len(rel); i++ {
+		if rel[i] == '/' {
+			if _, ignored := match(g, rel[:i], true); ignored {
+				return true
+			}
+		}
+	}
+	_, ignored := match(g, rel, false)
+	return ignored
+}
@@ -0,0 +1,71 @@
+package language
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// extensions lists the file extensions of each editor language id
+var extensions = map[string][]string{
+	"javascript": {"js", "mjs", "jsx"},
+	"typescript": {"ts", "tsx"},
+	"python":     {"py", "pyw"},
+	"php":        {"php", "phtml"},
+	"html":       {"html", "htm"},
+	"css":        {"css"},
+	"scss":       {"scss"},
+	"less":       {"less"},
+	"sass":       {"sass"},
+	"json":       {"json", "jsonc"},
+	"xml":        {"xml", "svg"},
+	"yaml":       {"yaml", "yml"},
+	"markdown":   {"md", "markdown"},
+	"sql":        {"sql"},
+	"shell":      {"sh", "bash", "zsh"},
+	"dockerfile": {"dockerfile"},
+	"go":         {"go"},
+	"rust":       {"rs"},
+	"java":       {"java"},
+	"c":          {"c", "h"},
+	"cpp":        {"cpp", "cc", "cxx", "hpp"},
+	"ruby":       {"rb"},
+	"swift":      {"swift"},
+	"kotlin":     {"kt", "kts"},
+	"lua":        {"lua"},
+	"r":          {"r"},
+	"perl":       {"pl", "pm"},
+	"ini":        {"ini", "conf", "cfg", "toml", "env"},
+	"vue":        {"vue"},
+	"svelte":     {"svelte"},
+}
+
+// byExtension maps each extension back to its language
+var byExtension = map[string]string{}
+
+func init() {
+	for lang, exts := range extensions {
+		for _, ext := range exts {
+			byExtension[ext] = lang
+		}
+	}
+}
+
+// Detect returns the editor language of a file from its name, or
+// "plaintext" if it isn't recognized
+func Detect(filename string) string {
+	// Special cases for files without extension
+	switch strings.ToLower(filepath.Base(filename)) {
+	case "dockerfile":
+		return "dockerfile"
+	case "makefile":
+		return "makefile"
+	case ".gitignore", ".env":
+		return "ini"
+	}
+
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))
+	if lang, ok := byExtension[ext]; ok {
+		return lang
+	}
+	return "plaintext"
+}
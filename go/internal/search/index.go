@@ -0,0 +1,561 @@
+package search
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/c00d-ide/c00d/internal/config"
+	"github.com/c00d-ide/c00d/internal/fileutil"
+	"github.com/c00d-ide/c00d/internal/ignore"
+	"github.com/c00d-ide/c00d/internal/watcher"
+)
+
+// Index states
+const (
+	IndexBuilding = "building" // First build running; searches read every file
+	IndexReady    = "ready"
+)
+
+const (
+	// indexVersion changes whenever the on-disk format does
+	indexVersion = 1
+
+	// rescanInterval is how often the whole root is checked for changes
+	// the watcher missed
+	rescanInterval = 10 * time.Minute
+
+	// saveInterval is how often updates are written to disk
+	saveInterval = time.Minute
+
+	// maxEventUpdates is how many changed paths are updated one by one
+	// before the whole root is rescanned instead
+	maxEventUpdates = 100
+)
+
+// Index is a trigram index of the text files in a workspace, in the style
+// of codesearch: for every three-byte sequence, the files containing it.
+// Searches use it to skip files that can't contain a match. It's stored
+// under the data directory and kept up to date from watcher events and
+// periodic rescans; files changed since they were indexed are always
+// searched, so results never depend on it being current.
+type Index struct {
+	Root string
+
+	build      sync.Mutex // Serializes builds and updates
+	progress   atomic.Int64
+	rebuilding atomic.Bool
+
+	mu    sync.RWMutex
+	data  *indexData
+	gen   int // Bumped whenever file IDs are reassigned
+	state string
+	dirty bool // Changed since last saved
+}
+
+// indexData is the content of an index, as stored on disk
+type indexData struct {
+	Version   int
+	Root      string
+	Files     []indexedFile // By ID; removed files keep their slot
+	Postings  map[uint32]*postingList
+	BuiltAt   time.Time
+	UpdatedAt time.Time
+
+	byPath  map[string]uint32
+	removed int
+}
+
+// indexedFile is a file as it was when indexed. Binary and large files
+// are recorded without trigrams, since searches skip them anyway.
+type indexedFile struct {
+	Path    string
+	Size    int64
+	ModTime int64 // Unix nanoseconds
+	Removed bool
+}
+
+// IndexStatus describes an index for the API
+type IndexStatus struct {
+	State      string    `json:"state"`
+	Rebuilding bool      `json:"rebuilding"`
+	Progress   int64     `json:"progress"` // Files read by the running build
+	Files      int       `json:"files"`
+	Trigrams   int       `json:"trigrams"`
+	Size       int64     `json:"size"` // Bytes of posting lists
+	BuiltAt    time.Time `json:"built_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+var (
+	indexesMu sync.Mutex
+	indexes   = map[string]*Index{}
+)
+
+// IndexFor returns the index of root, loading or building it on first
+// use, or nil if search.index is off
+func IndexFor(root string) *Index {
+	if !config.C.Search.Index {
+		return nil
+	}
+
+	indexesMu.Lock()
+	defer indexesMu.Unlock()
+
+	if ix, ok := indexes[root]; ok {
+		return ix
+	}
+
+	ix := &Index{Root: root, data: newIndexData(root), state: IndexBuilding}
+	go func() {
+		if ix.load() {
+			ix.Rescan()
+		} else {
+			ix.Rebuild()
+		}
+		ix.follow()
+	}()
+
+	indexes[root] = ix
+	return ix
+}
+
+func newIndexData(root string) *indexData {
+	return &indexData{
+		Version:  indexVersion,
+		Root:     root,
+		Postings: map[uint32]*postingList{},
+		byPath:   map[string]uint32{},
+	}
+}
+
+// add indexes a file, replacing any earlier version of it
+func (d *indexData) add(f indexedFile, tris []uint32) {
+	d.remove(f.Path)
+	id := uint32(len(d.Files))
+	d.Files = append(d.Files, f)
+	d.byPath[f.Path] = id
+	for _, t := range tris {
+		p := d.Postings[t]
+		if p == nil {
+			p = &postingList{}
+			d.Postings[t] = p
+		}
+		p.add(id)
+	}
+}
+
+// remove marks a file removed. Its ID stays in the posting lists until
+// the next rebuild, which only ever makes the index match more files.
+func (d *indexData) remove(rel string) {
+	if id, ok := d.byPath[rel]; ok {
+		d.Files[id].Removed = true
+		delete(d.byPath, rel)
+		d.removed++
+	}
+}
+
+// Status returns the state and size of the index
+func (ix *Index) Status() IndexStatus {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	var size int64
+	for _, p := range ix.data.Postings {
+		size += int64(len(p.Data))
+	}
+	return IndexStatus{
+		State:      ix.state,
+		Rebuilding: ix.rebuilding.Load(),
+		Progress:   ix.progress.Load(),
+		Files:      len(ix.data.byPath),
+		Trigrams:   len(ix.data.Postings),
+		Size:       size,
+		BuiltAt:    ix.data.BuiltAt,
+		UpdatedAt:  ix.data.UpdatedAt,
+	}
+}
+
+// Filter returns a function reporting whether the file rel, currently
+// described by info, can be skipped when searching for pattern: it was
+// indexed, hasn't changed since, and lacks trigrams every match needs.
+// It returns nil when the index can't narrow the search.
+func (ix *Index) Filter(pattern *regexp.Regexp) func(rel string, info fs.FileInfo) bool {
+	q := planQuery(pattern.String())
+	if q.op == queryAll {
+		return nil
+	}
+
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+	if ix.state != IndexReady {
+		return nil
+	}
+
+	candidates := make([]bool, len(ix.data.Files))
+	for _, id := range ix.eval(q) {
+		candidates[id] = true
+	}
+	gen := ix.gen
+
+	return func(rel string, info fs.FileInfo) bool {
+		ix.mu.RLock()
+		defer ix.mu.RUnlock()
+		id, ok := ix.data.byPath[rel]
+		if !ok || ix.gen != gen || int(id) >= len(candidates) || candidates[id] {
+			return false
+		}
+		f := ix.data.Files[id]
+		return f.Size == info.Size() && f.ModTime == info.ModTime().UnixNano()
+	}
+}
+
+// eval returns the sorted IDs of the files meeting q, or nil for all
+// files. The caller holds ix.mu.
+func (ix *Index) eval(q *trigramQuery) []uint32 {
+	switch q.op {
+	case queryAnd:
+		var ids []uint32
+		all := true
+		for _, t := range q.tris {
+			p := ix.data.Postings[t]
+			if p == nil {
+				return []uint32{}
+			}
+			ids, all = intersect(ids, all, p.ids()), false
+		}
+		for _, sub := range q.sub {
+			if subIDs := ix.eval(sub); subIDs != nil {
+				ids, all = intersect(ids, all, subIDs), false
+			}
+		}
+		if all {
+			return nil
+		}
+		return ids
+
+	case queryOr:
+		ids := []uint32{}
+		for _, sub := range q.sub {
+			subIDs := ix.eval(sub)
+			if subIDs == nil {
+				return nil
+			}
+			ids = union(ids, subIDs)
+		}
+		return ids
+	}
+	return nil
+}
+
+// intersect returns the IDs in both a and b, with all meaning a is every file
+func intersect(a []uint32, all bool, b []uint32) []uint32 {
+	if all {
+		return b
+	}
+	out := []uint32{}
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] < b[j]:
+			i++
+		case a[i] > b[j]:
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	return out
+}
+
+func union(a, b []uint32) []uint32 {
+	out := make([]uint32, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] < b[j]:
+			out = append(out, a[i])
+			i++
+		case a[i] > b[j]:
+			out = append(out, b[j])
+			j++
+		default:
+			out = append(out, a[i])
+			i++
+			j++
+		}
+	}
+	out = append(out, a[i:]...)
+	return append(out, b[j:]...)
+}
+
+// Rebuild indexes every file of the root again. Searches keep using the
+// old index meanwhile.
+func (ix *Index) Rebuild() {
+	ix.build.Lock()
+	defer ix.build.Unlock()
+	ix.rebuild()
+}
+
+// StartRebuild rebuilds the index in the background
+func (ix *Index) StartRebuild() {
+	ix.rebuilding.Store(true)
+	go ix.Rebuild()
+}
+
+func (ix *Index) rebuild() {
+	ix.rebuilding.Store(true)
+	defer ix.rebuilding.Store(false)
+	ix.progress.Store(0)
+
+	var paths []string
+	for rel := range ix.list("") {
+		paths = append(paths, rel)
+	}
+	data := newIndexData(ix.Root)
+	ix.read(paths, func(r readResult) {
+		data.add(r.file, r.tris)
+	})
+	data.BuiltAt = time.Now()
+	data.UpdatedAt = data.BuiltAt
+
+	ix.mu.Lock()
+	ix.data = data
+	ix.gen++
+	ix.state = IndexReady
+	ix.dirty = true
+	ix.mu.Unlock()
+
+	ix.save()
+}
+
+// Rescan brings the index up to date with the whole root
+func (ix *Index) Rescan() {
+	ix.update("")
+}
+
+// update brings the index up to date with the files under dir, a path
+// relative to the root ("" for all of it), which may also be a single
+// file or a path that no longer exists
+func (ix *Index) update(dir string) {
+	ix.build.Lock()
+	defer ix.build.Unlock()
+
+	current := ix.list(dir)
+
+	var changed []string
+	var gone []string
+	ix.mu.RLock()
+	for rel, info := range current {
+		id, ok := ix.data.byPath[rel]
+		if !ok || ix.data.Files[id].Size != info.Size() || ix.data.Files[id].ModTime != info.ModTime().UnixNano() {
+			changed = append(changed, rel)
+		}
+	}
+	// A file that still exists can't have taken anything with it
+	if _, isFile := current[dir]; !isFile {
+		for rel := range ix.data.byPath {
+			if _, ok := current[rel]; !ok && under(rel, dir) {
+				gone = append(gone, rel)
+			}
+		}
+	}
+	ix.mu.RUnlock()
+
+	if len(changed) == 0 && len(gone) == 0 {
+		return
+	}
+	var files []readResult
+	ix.read(changed, func(r readResult) {
+		files = append(files, r)
+	})
+
+	ix.mu.Lock()
+	for _, rel := range gone {
+		ix.data.remove(rel)
+	}
+	for _, r := range files {
+		ix.data.add(r.file, r.tris)
+	}
+	ix.data.UpdatedAt = time.Now()
+	ix.dirty = true
+	// Removed files still take up their IDs; start afresh once they
+	// outnumber the live ones
+	compact := ix.data.removed > 1000 && ix.data.removed > len(ix.data.byPath)
+	ix.mu.Unlock()
+
+	if compact {
+		ix.rebuild()
+	}
+}
+
+// under reports whether slash-separated path p is dir or inside it
+func under(p, dir string) bool {
+	return dir == "" || p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+// list returns the visible files under dir with their current info
+func (ix *Index) list(dir string) map[string]fs.FileInfo {
+	files := map[string]fs.FileInfo{}
+	start := filepath.Join(ix.Root, filepath.FromSlash(dir))
+	info, err := os.Lstat(start)
+	if err != nil || (dir != "" && ignore.For(ix.Root).Ignored(dir, info.IsDir())) {
+		return files
+	}
+	walkFiles(ix.Root, start, func(p string, d fs.DirEntry) error {
+		if info, err := os.Stat(p); err == nil {
+			rel, _ := filepath.Rel(ix.Root, p)
+			files[filepath.ToSlash(rel)] = info
+		}
+		return nil
+	})
+	return files
+}
+
+type readResult struct {
+	file indexedFile
+	tris []uint32
+}
+
+// read reads files on a pool of workers and calls fn with the trigrams of
+// each, from the calling goroutine. Files that can't be read are left out.
+func (ix *Index) read(paths []string, fn func(readResult)) {
+	jobs := make(chan string)
+	results := make(chan readResult)
+	var wg sync.WaitGroup
+	for i := 0; i < workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for rel := range jobs {
+				content, info, err := readFile(filepath.Join(ix.Root, filepath.FromSlash(rel)))
+				ix.progress.Add(1)
+				if err != nil {
+					continue
+				}
+				r := readResult{file: indexedFile{Path: rel, Size: info.Size(), ModTime: info.ModTime().UnixNano()}}
+				if content != nil && !fileutil.IsBinary(content) {
+					r.tris = trigramsOf(content)
+				}
+				results <- r
+			}
+		}()
+	}
+	go func() {
+		for _, rel := range paths {
+			jobs <- rel
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	for r := range results {
+		fn(r)
+	}
+}
+
+// follow keeps the index up to date until the process exits: changed
+// paths are updated as the watcher reports them, everything is rescanned
+// periodically and after lost events, and changes are saved now and then
+func (ix *Index) follow() {
+	events, _ := watcher.For(ix.Root).Subscribe()
+	rescan := time.NewTicker(rescanInterval)
+	save := time.NewTicker(saveInterval)
+	for {
+		select {
+		case batch, ok := <-events:
+			if !ok {
+				return
+			}
+			paths := map[string]bool{}
+			for _, ev := range batch {
+				paths[ev.Path] = true
+				if ev.OldPath != "" {
+					paths[ev.OldPath] = true
+				}
+				// After lost events, or a change to many files such as a
+				// checkout, one rescan does it all
+				if ev.Type == watcher.Overflow || len(paths) > maxEventUpdates {
+					paths = map[string]bool{"": true}
+					break
+				}
+			}
+			for p := range paths {
+				ix.update(p)
+			}
+
+		case <-rescan.C:
+			ix.Rescan()
+
+		case <-save.C:
+			ix.save()
+		}
+	}
+}
+
+// path returns where the index of the root is stored
+func (ix *Index) path() string {
+	sum := sha256.Sum256([]byte(ix.Root))
+	return filepath.Join(config.C.DataDir, "index", hex.EncodeToString(sum[:8])+".idx")
+}
+
+// load reads the stored index, reporting whether there was a usable one
+func (ix *Index) load() bool {
+	raw, err := os.ReadFile(ix.path())
+	if err != nil {
+		return false
+	}
+	data := &indexData{}
+	if err := gob.NewDecoder(bytes.NewReader(raw)).Decode(data); err != nil || data.Version != indexVersion || data.Root != ix.Root {
+		return false
+	}
+	data.byPath = map[string]uint32{}
+	for id, f := range data.Files {
+		if f.Removed {
+			data.removed++
+		} else {
+			data.byPath[f.Path] = uint32(id)
+		}
+	}
+
+	ix.mu.Lock()
+	ix.data = data
+	ix.gen++
+	ix.state = IndexReady
+	ix.mu.Unlock()
+	return true
+}
+
+// save writes the index to disk if it changed
+func (ix *Index) save() {
+	ix.mu.Lock()
+	if !ix.dirty {
+		ix.mu.Unlock()
+		return
+	}
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(ix.data)
+	ix.dirty = false
+	ix.mu.Unlock()
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(ix.path()), 0700)
+	}
+	if err == nil {
+		err = fileutil.WriteFileAtomic(ix.path(), buf.Bytes(), 0600)
+	}
+	if err != nil {
+		log.Printf("search index of %s not saved: %v", ix.Root, err)
+	}
+}
@@ -0,0 +1,54 @@
+package search
+
+import (
+	"bytes"
+	"regexp"
+)
+
+// Change is a line a replacement changes
+type Change struct {
+	Line   int    `json:"line"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// Replace replaces the matches of pattern in content line by line, like
+// the search matches them, keeping line endings as they are. Unless
+// literal is set, $1 or ${name} in replacement stand for the text of a
+// capture group. Only lines for which keep returns true are changed; a
+// nil keep changes them all.
+func Replace(content []byte, pattern *regexp.Regexp, replacement string, literal bool, keep func(line int) bool) ([]byte, []Change) {
+	var out bytes.Buffer
+	var changes []Change
+	repl := []byte(replacement)
+
+	for i, line := range bytes.SplitAfter(content, []byte("\n")) {
+		if len(line) == 0 {
+			break // Nothing after the final newline
+		}
+		text, ending := line, []byte(nil)
+		if bytes.HasSuffix(text, []byte("\n")) {
+			n := len(text) - 1
+			if n > 0 && text[n-1] == '\r' {
+				n--
+			}
+			text, ending = text[:n], text[n:]
+		}
+
+		if (keep == nil || keep(i+1)) && pattern.Match(text) {
+			var replaced []byte
+			if literal {
+				replaced = pattern.ReplaceAllLiteral(text, repl)
+			} else {
+				replaced = pattern.ReplaceAll(text, repl)
+			}
+			if !bytes.Equal(replaced, text) {
+				changes = append(changes, Change{Line: i + 1, Before: string(text), After: string(replaced)})
+				text = replaced
+			}
+		}
+		out.Write(text)
+		out.Write(ending)
+	}
+	return out.Bytes(), changes
+}
@@ -0,0 +1,403 @@
+package search
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"unicode/utf8"
+
+	"github.com/c00d-ide/c00d/internal/fileutil"
+	"github.com/c00d-ide/c00d/internal/ignore"
+	"github.com/c00d-ide/c00d/internal/security"
+)
+
+const (
+	// MaxFileSize is the largest file searched; larger ones are skipped
+	MaxFileSize = 1024 * 1024
+
+	// maxColumns is how many matches within one line are reported
+	maxColumns = 100
+)
+
+// Query describes a content search
+type Query struct {
+	Root       string         // Workspace root; results are relative to it
+	Dir        string         // Directory searched, within Root
+	Pattern    *regexp.Regexp // Matched against each line
+	FileGlob   string         // Only search files whose name matches, if set
+	Include    ignore.Globs   // Only search files matching these, if any
+	Exclude    ignore.Globs   // Leave out files matching these
+	Before     int            // Context lines sent above each match
+	After      int            // Context lines sent below each match
+	MaxResults int            // Stop after this many matches (0 = no limit)
+}
+
+// Match is a line matching a query
+type Match struct {
+	File    string   `json:"file"`
+	Line    int      `json:"line"`
+	Content string   `json:"content"`
+	Columns []Range  `json:"columns"`
+	Before  []string `json:"before,omitempty"` // Lines above, nearest last
+	After   []string `json:"after,omitempty"`  // Lines below
+}
+
+// Range is where a match is in a line, in 1-based columns that count
+// UTF-16 code units like the editor does. End is exclusive.
+type Range struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Stats summarizes a finished search
+type Stats struct {
+	Files     int  `json:"files"`     // Files searched
+	Binary    int  `json:"binary"`    // Binary files skipped
+	Skipped   int  `json:"skipped"`   // Files the index ruled out without reading them
+	Count     int  `json:"count"`     // Matches found
+	Truncated bool `json:"truncated"` // More than MaxResults matched
+	Indexed   bool `json:"indexed"`   // The index narrowed the search
+}
+
+// Run searches the files under q.Dir on a pool of workers, calling emit
+// with the matches of each file as soon as it has been searched. Matches
+// within a file are in line order; files come in no particular order.
+// emit is only called from the calling goroutine. The search stops early
+// when ctx is done or a match past MaxResults turns up, which is dropped
+// and marks the results truncated. Files the workspace's index rules out
+// aren't read.
+func Run(ctx context.Context, q Query, emit func([]Match)) Stats {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// Most files don't match at all; one pass over the whole content rules
+	// them out before splitting lines. With (?m), ^ and $ still match at
+	// line boundaries, so this never misses a line that matches. \A and \z
+	// would only match at the ends of the file, so those go without.
+	var prefilter *regexp.Regexp
+	if expr := q.Pattern.String(); !strings.Contains(expr, `\A`) && !strings.Contains(expr, `\z`) {
+		prefilter, _ = regexp.Compile("(?m)" + expr)
+	}
+
+	var skip func(rel string, info fs.FileInfo) bool
+	if ix := IndexFor(q.Root); ix != nil {
+		skip = ix.Filter(q.Pattern)
+	}
+
+	paths := make(chan string, 256)
+	results := make(chan []Match, 64)
+	var files, binary, skipped atomic.Int64
+
+	go func() {
+		defer close(paths)
+		walk(ctx, q, skip, &skipped, paths)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for p := range paths {
+				if ctx.Err() != nil {
+					continue // Drain so the walker can finish
+				}
+				matches, searched, isBinary := searchFile(q, prefilter, p)
+				if searched {
+					files.Add(1)
+				}
+				if isBinary {
+					binary.Add(1)
+				}
+				if len(matches) > 0 {
+					select {
+					case results <- matches:
+					case <-ctx.Done():
+					}
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	stats := Stats{}
+	for matches := range results {
+		if ctx.Err() != nil {
+			continue
+		}
+		// Only a match past the limit means the results are cut short
+		if q.MaxResults > 0 && stats.Count+len(matches) > q.MaxResults {
+			matches = matches[:q.MaxResults-stats.Count]
+			stats.Truncated = true
+			cancel()
+		}
+		stats.Count += len(matches)
+		if len(matches) > 0 {
+			emit(matches)
+		}
+	}
+	stats.Files = int(files.Load())
+	stats.Binary = int(binary.Load())
+	stats.Skipped = int(skipped.Load())
+	stats.Indexed = skip != nil
+	return stats
+}
+
+// workers returns how many files are searched at once. Searching is
+// mostly waiting on reads, so use at least a few even on one CPU.
+func workers() int {
+	n := runtime.NumCPU()
+	if n < 4 {
+		n = 4
+	}
+	return n
+}
+
+// walk sends the files under q.Dir that skip, if set, doesn't rule out
+// to paths, stopping when ctx is done
+func walk(ctx context.Context, q Query, skip func(string, fs.FileInfo) bool, skipped *atomic.Int64, paths chan<- string) {
+	walkFiles(q.Root, q.Dir, func(p string, d fs.DirEntry) error {
+		if ctx.Err() != nil {
+			return fs.SkipAll
+		}
+		rel, _ := filepath.Rel(q.Root, p)
+		rel = filepath.ToSlash(rel)
+		if !q.selects(rel) {
+			return nil
+		}
+		if skip != nil {
+			if info, err := os.Stat(p); err == nil && skip(rel, info) {
+				skipped.Add(1)
+				return nil
+			}
+		}
+
+		select {
+		case paths <- p:
+			return nil
+		case <-ctx.Done():
+			return fs.SkipAll
+		}
+	})
+}
+
+// selects reports whether the file at rel passes the file glob and the
+// include and exclude globs
+func (q Query) selects(rel string) bool {
+	if q.FileGlob != "" {
+		if matched, _ := filepath.Match(q.FileGlob, path.Base(rel)); !matched {
+			return false
+		}
+	}
+	return (len(q.Include) == 0 || q.Include.Match(rel)) && !q.Exclude.Match(rel)
+}
+
+// Covers reports whether the file at fullPath is one q would search: under
+// q.Dir, not hidden below it, and selected by its globs
+func (q Query) Covers(fullPath string) bool {
+	sub, err := filepath.Rel(q.Dir, fullPath)
+	if err != nil || sub == "." || !filepath.IsLocal(sub) {
+		return false
+	}
+	matcher := ignore.For(q.Root)
+	parts := strings.Split(sub, string(filepath.Separator))
+	cur := q.Dir
+	for i, part := range parts {
+		cur = filepath.Join(cur, part)
+		if matcher.MatchPath(cur, i < len(parts)-1) {
+			return false
+		}
+	}
+	rel, _ := filepath.Rel(q.Root, fullPath)
+	return q.selects(filepath.ToSlash(rel))
+}
+
+// walkFiles calls fn for each file under dir that the workspace's
+// visibility rules don't hide, leaving out symlinks leading outside the
+// workspace. dir itself is walked even if hidden. fn may return
+// fs.SkipAll to stop.
+func walkFiles(root, dir string, fn func(fullPath string, d fs.DirEntry) error) {
+	matcher := ignore.For(root)
+	filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+
+		// Skip hidden and excluded files and directories
+		if p != dir && matcher.MatchPath(p, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		// Don't read through symlinks leading outside the workspace
+		if d.Type()&fs.ModeSymlink != 0 && security.Check(root, p, true) != nil {
+			return nil
+		}
+		return fn(p, d)
+	})
+}
+
+// searchFile returns the matching lines of the file at fullPath. searched
+// is false for files that weren't read: unreadable ones, special files and
+// files over MaxFileSize.
+func searchFile(q Query, prefilter *regexp.Regexp, fullPath string) (matches []Match, searched, binary bool) {
+	content, _, err := readFile(fullPath)
+	if err != nil || content == nil {
+		return nil, false, false
+	}
+	if fileutil.IsBinary(content) {
+		return nil, true, true
+	}
+	if prefilter != nil {
+		// Lines are matched without their \r, so $ must match before it here too
+		text := content
+		if bytes.IndexByte(text, '\r') >= 0 {
+			text = bytes.ReplaceAll(text, []byte("\r\n"), []byte("\n"))
+		}
+		if !prefilter.Match(text) {
+			return nil, true, false
+		}
+	}
+
+	rel, _ := filepath.Rel(q.Root, fullPath)
+	lines := splitLines(content)
+	for i, line := range lines {
+		spans := q.Pattern.FindAllIndex(line, maxColumns)
+		if spans == nil {
+			continue
+		}
+		matches = append(matches, Match{
+			File:    filepath.ToSlash(rel),
+			Line:    i + 1,
+			Content: string(line),
+			Columns: columns(line, spans),
+			Before:  lineStrings(lines, i-q.Before, i),
+			After:   lineStrings(lines, i+1, i+1+q.After),
+		})
+		if q.MaxResults > 0 && len(matches) > q.MaxResults {
+			break // One past the limit, so Run can tell there were more
+		}
+	}
+	return matches, true, false
+}
+
+// splitLines splits content into lines without their line endings
+func splitLines(content []byte) [][]byte {
+	lines := bytes.Split(content, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1] // Nothing after the final newline
+	}
+	for i, line := range lines {
+		lines[i] = bytes.TrimSuffix(line, []byte("\r"))
+	}
+	return lines
+}
+
+// lineStrings returns lines[from:to], clamped to the lines there are
+func lineStrings(lines [][]byte, from, to int) []string {
+	from = max(from, 0)
+	to = min(to, len(lines))
+	if from >= to {
+		return nil
+	}
+	out := make([]string, 0, to-from)
+	for _, line := range lines[from:to] {
+		out = append(out, string(line))
+	}
+	return out
+}
+
+// columns converts byte offsets of matches in line to editor columns
+func columns(line []byte, spans [][]int) []Range {
+	ranges := make([]Range, 0, len(spans))
+	pos, col := 0, 1
+	advance := func(to int) int {
+		for pos < to {
+			r, size := utf8.DecodeRune(line[pos:])
+			pos += size
+			col++
+			if r >= 0x10000 {
+				col++ // Surrogate pair
+			}
+		}
+		return col
+	}
+	for _, span := range spans {
+		start := advance(span[0])
+		ranges = append(ranges, Range{Start: start, End: advance(span[1])})
+	}
+	return ranges
+}
+
+// Pattern compiles a query. Plain queries match literally. Unless
+// caseSensitive is set, letter case is ignored. wholeWord requires
+// matches not to be part of a longer word: for plain queries, on the
+// sides where the query starts or ends with a word character.
+func Pattern(query string, isRegex, caseSensitive, wholeWord bool) (*regexp.Regexp, error) {
+	expr := query
+	if !isRegex {
+		expr = regexp.QuoteMeta(query)
+	}
+	if wholeWord {
+		if isRegex {
+			expr = `\b(?:` + expr + `)\b`
+		} else {
+			if r, _ := utf8.DecodeRuneInString(query); isWordChar(r) {
+				expr = `\b` + expr
+			}
+			if r, _ := utf8.DecodeLastRuneInString(query); isWordChar(r) {
+				expr += `\b`
+			}
+		}
+	}
+	if !caseSensitive {
+		expr = "(?i)" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+// isWordChar reports whether \b counts r as part of a word
+func isWordChar(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// readFile reads the file at fullPath, returning its info from before the
+// read. content is nil for files that aren't searched: special files and
+// files over MaxFileSize.
+func readFile(fullPath string) ([]byte, fs.FileInfo, error) {
+	// Opening a FIFO or reading a device would block or never end
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !info.Mode().IsRegular() || info.Size() > MaxFileSize {
+		return nil, info, nil
+	}
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(io.LimitReader(f, MaxFileSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, info, nil
+}
@@ -0,0 +1,95 @@
+package search
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/c00d-ide/c00d/internal/ignore"
+)
+
+// workspace creates a directory holding files, keyed by name
+func workspace(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func run(t *testing.T, root, expr string, maxResults int) ([]Match, Stats) {
+	t.Helper()
+	pattern, err := Pattern(expr, true, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var matches []Match
+	stats := Run(context.Background(), Query{Root: root, Dir: root, Pattern: pattern, MaxResults: maxResults}, func(m []Match) {
+		matches = append(matches, m...)
+	})
+	return matches, stats
+}
+
+func TestRunCRLF(t *testing.T) {
+	root := workspace(t, map[string]string{
+		"lf.txt":   "call foo\nbar\n",
+		"crlf.txt": "call foo\r\nbar\r\n",
+	})
+	matches, _ := run(t, root, `foo$`, 0)
+	if len(matches) != 2 {
+		t.Fatalf("foo$ matched %d lines, want 2: %+v", len(matches), matches)
+	}
+	for _, m := range matches {
+		if m.Content != "call foo" {
+			t.Errorf("%s: content %q", m.File, m.Content)
+		}
+	}
+}
+
+func TestRunTruncated(t *testing.T) {
+	root := workspace(t, map[string]string{"a.txt": "x\nx\nx\n"})
+
+	matches, stats := run(t, root, `x`, 3)
+	if len(matches) != 3 || stats.Truncated {
+		t.Errorf("limit 3: %d matches, truncated %v; want 3, false", len(matches), stats.Truncated)
+	}
+	matches, stats = run(t, root, `x`, 2)
+	if len(matches) != 2 || !stats.Truncated {
+		t.Errorf("limit 2: %d matches, truncated %v; want 2, true", len(matches), stats.Truncated)
+	}
+}
+
+func TestQueryCovers(t *testing.T) {
+	root := workspace(t, map[string]string{
+		"src/a.go":      "",
+		"src/a_test.go": "",
+		"src/.env":      "",
+		"docs/a.go":     "",
+	})
+	q := Query{
+		Root:    root,
+		Dir:     filepath.Join(root, "src"),
+		Include: ignore.ParseGlobs([]string{"*.go"}),
+		Exclude: ignore.ParseGlobs([]string{"*_test.go"}),
+	}
+	for rel, want := range map[string]bool{
+		"src/a.go":      true,
+		"src/a_test.go": false, // Excluded
+		"src/.env":      false, // Hidden, and not included
+		"src/b.go":      true,  // Not there yet, but in scope
+		"docs/a.go":     false, // Outside Dir
+		"src":           false,
+	} {
+		if got := q.Covers(filepath.Join(root, filepath.FromSlash(rel))); got != want {
+			t.Errorf("Covers(%s) = %v, want %v", rel, got, want)
+		}
+	}
+}
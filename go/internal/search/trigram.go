@@ -0,0 +1,175 @@
+package search
+
+import (
+	"encoding/binary"
+	"regexp/syntax"
+	"sort"
+)
+
+// trigramsOf returns the distinct trigrams of content, sorted. Letters are
+// lowercased so case-insensitive queries can use the index too. Trigrams
+// spanning a line break are left out: matches never do.
+func trigramsOf(content []byte) []uint32 {
+	if len(content) < 3 {
+		return nil
+	}
+	tris := make([]uint32, 0, len(content)/4)
+	var t uint32
+	for i, b := range content {
+		t = (t<<8 | uint32(lower(b))) & 0xffffff
+		if i >= 2 && b != '\n' && content[i-1] != '\n' && content[i-2] != '\n' {
+			tris = append(tris, t)
+		}
+	}
+	sort.Slice(tris, func(i, j int) bool { return tris[i] < tris[j] })
+	n := 0
+	for i, t := range tris {
+		if i == 0 || t != tris[n-1] {
+			tris[n] = t
+			n++
+		}
+	}
+	return tris[:n]
+}
+
+func lower(b byte) byte {
+	if b >= 'A' && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
+
+// postingList holds the sorted IDs of the files containing a trigram, as
+// varint-encoded deltas
+type postingList struct {
+	Data []byte
+	Last uint32
+	N    int
+}
+
+// add appends id, which must be larger than any ID already in the list
+func (p *postingList) add(id uint32) {
+	p.Data = binary.AppendUvarint(p.Data, uint64(id-p.Last))
+	p.Last = id
+	p.N++
+}
+
+func (p *postingList) ids() []uint32 {
+	ids := make([]uint32, 0, p.N)
+	var id uint32
+	for data := p.Data; len(data) > 0; {
+		delta, n := binary.Uvarint(data)
+		id += uint32(delta)
+		ids = append(ids, id)
+		data = data[n:]
+	}
+	return ids
+}
+
+// trigramQuery is a condition a file's trigrams must meet for it to
+// possibly contain a match
+type trigramQuery struct {
+	op   queryOp
+	tris []uint32        // queryAnd: all of these
+	sub  []*trigramQuery // queryAnd: all of these too; queryOr: any of them
+}
+
+type queryOp int
+
+const (
+	queryAll queryOp = iota // Any file may match
+	queryAnd
+	queryOr
+)
+
+var matchAll = &trigramQuery{op: queryAll}
+
+// planQuery works out which trigrams a file must contain for a line of it
+// to match expr. It only ever narrows by literal text the regexp
+// requires, so every file that could match meets the query.
+func planQuery(expr string) *trigramQuery {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return matchAll
+	}
+	return plan(re.Simplify())
+}
+
+func plan(re *syntax.Regexp) *trigramQuery {
+	switch re.Op {
+	case syntax.OpLiteral:
+		return literalQuery(re.Rune, re.Flags&syntax.FoldCase != 0)
+
+	case syntax.OpCapture, syntax.OpPlus:
+		return plan(re.Sub[0])
+
+	case syntax.OpRepeat:
+		if re.Min >= 1 {
+			return plan(re.Sub[0])
+		}
+
+	case syntax.OpConcat:
+		q := &trigramQuery{op: queryAnd}
+		for _, sub := range re.Sub {
+			q.and(plan(sub))
+		}
+		return q.simplify()
+
+	case syntax.OpAlternate:
+		q := &trigramQuery{op: queryOr}
+		for _, sub := range re.Sub {
+			s := plan(sub)
+			if s.op == queryAll {
+				return matchAll
+			}
+			q.sub = append(q.sub, s)
+		}
+		return q
+	}
+	return matchAll
+}
+
+// literalQuery requires the trigrams of a literal. Case-insensitive
+// literals are split where a character folds to something other than its
+// ASCII lower or upper case, such as k (the Kelvin sign) or any non-ASCII
+// letter, since the index only lowercases ASCII.
+func literalQuery(runes []rune, fold bool) *trigramQuery {
+	q := &trigramQuery{op: queryAnd}
+	var piece []byte
+	flush := func() {
+		q.tris = append(q.tris, trigramsOf(piece)...)
+		piece = piece[:0]
+	}
+	for _, r := range runes {
+		if fold && (r >= 0x80 || r == 'k' || r == 'K' || r == 's' || r == 'S') {
+			flush()
+			continue
+		}
+		piece = append(piece, string(r)...)
+	}
+	flush()
+	return q.simplify()
+}
+
+// and adds the conditions of s to q, an AND query
+func (q *trigramQuery) and(s *trigramQuery) {
+	switch s.op {
+	case queryAnd:
+		q.tris = append(q.tris, s.tris...)
+		q.sub = append(q.sub, s.sub...)
+	case queryOr:
+		q.sub = append(q.sub, s)
+	}
+}
+
+func (q *trigramQuery) simplify() *trigramQuery {
+	if q.op == queryAnd && len(q.tris) == 0 {
+		switch len(q.sub) {
+		case 0:
+			return matchAll
+		case 1:
+			return q.sub[0]
+		}
+	}
+	return q
+}
@@ -0,0 +1,159 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/c00d-ide/c00d/internal/config"
+)
+
+// describe writes q out as all, and(...) or or(...), with trigrams quoted
+func describe(q *trigramQuery) string {
+	switch q.op {
+	case queryAnd:
+		var parts []string
+		for _, t := range q.tris {
+			parts = append(parts, fmt.Sprintf("%q", string([]byte{byte(t >> 16), byte(t >> 8), byte(t)})))
+		}
+		for _, sub := range q.sub {
+			parts = append(parts, describe(sub))
+		}
+		return "and(" + strings.Join(parts, " ") + ")"
+	case queryOr:
+		var parts []string
+		for _, sub := range q.sub {
+			parts = append(parts, describe(sub))
+		}
+		return "or(" + strings.Join(parts, " ") + ")"
+	}
+	return "all"
+}
+
+func TestPlanQuery(t *testing.T) {
+	for _, tt := range []struct{ expr, want string }{
+		{`foo`, `and("foo")`},
+		{`foobar`, `and("bar" "foo" "oba" "oob")`},
+		{`fo`, `all`},
+		{`(`, `all`}, // Invalid
+
+		// The index is lowercased, so case only matters where folding
+		// leaves ASCII: k and s also fold to the Kelvin sign and long s
+		{`(?i)FOO`, `and("foo")`},
+		{`(?i)kelvin`, `and("elv" "lvi" "vin")`},
+		{`(?i)class`, `and("cla")`},
+		{"(?i)\u017ftop", `and("top")`},
+		{`(?i)k`, `all`},
+		{`(?i)été`, `all`},
+		{`café`, `and("af\xc3" "caf" "fé")`},
+		{`x(?i:abc)y`, `and("abc")`},
+		{`[Ff]oo`, `all`},
+
+		// Alternation needs every branch to require something
+		{`foo|bar`, `or(and("foo") and("bar"))`},
+		{`foo|b`, `all`},
+		{`abcd|abce`, `and("abc")`},
+		{`abc(def|ghi)`, `and("abc" or(and("def") and("ghi")))`},
+
+		// Optional parts require nothing
+		{`(foo)?bar`, `and("bar")`},
+		{`(foo)*`, `all`},
+		{`(abc)+`, `and("abc")`},
+		{`(abc){0,2}`, `all`},
+		{`fo.o`, `all`},
+
+		// Anchors and word boundaries match no text
+		{`^foo$`, `and("foo")`},
+		{`(?i)\bhello\b`, `and("ell" "hel" "llo")`},
+
+		// Matches are within a line, and no trigram spans one
+		{`a\nbcd`, `and("bcd")`},
+	} {
+		if got := describe(planQuery(tt.expr)); got != tt.want {
+			t.Errorf("planQuery(%q) = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+// TestIndexSameResults checks that searches find the same lines with the
+// index narrowing them as without it
+func TestIndexSameResults(t *testing.T) {
+	root := workspace(t, map[string]string{
+		"plain.txt":   "hello world\nfoo bar baz\n",
+		"case.txt":    "HELLO World\nFooBar\n",
+		"kelvin.txt":  "temperature in \u212aelvin\n",
+		"longs.txt":   "\u017ftop here\n",
+		"crlf.txt":    "call foo\r\nbar\r\n",
+		"unicode.txt": "café olé\nÉTÉ\n",
+		"alt.go":      "func abcdef() {}\nvar ghi = 1\n",
+		"none.txt":    "nothing to see\n",
+	})
+
+	saved := config.C
+	t.Cleanup(func() { config.C = saved })
+	config.C.DataDir = t.TempDir()
+	config.C.Search.Index = true
+	ix := IndexFor(root)
+	deadline := time.Now().Add(10 * time.Second)
+	for ix.Status().State != IndexReady {
+		if time.Now().After(deadline) {
+			t.Fatal("index not built")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	type search struct {
+		query                           string
+		regex, caseSensitive, wholeWord bool
+	}
+	narrowed := false
+	for _, s := range []search{
+		{query: "hello"},
+		{query: "hello", caseSensitive: true},
+		{query: "world", wholeWord: true},
+		{query: "kelvin"},
+		{query: "stop"},
+		{query: "café"},
+		{query: "été"},
+		{query: "foo$", regex: true},
+		{query: "foo|ghi", regex: true},
+		{query: "abc(def|xyz)", regex: true},
+		{query: "(foo)?bar", regex: true},
+		{query: "o[lw]", regex: true},
+		{query: "nothing at all"},
+	} {
+		pattern, err := Pattern(s.query, s.regex, s.caseSensitive, s.wholeWord)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := Query{Root: root, Dir: root, Pattern: pattern}
+
+		config.C.Search.Index = false
+		without, _ := lines(q)
+		config.C.Search.Index = true
+		with, stats := lines(q)
+		narrowed = narrowed || stats.Skipped > 0
+
+		if strings.Join(with, "\n") != strings.Join(without, "\n") {
+			t.Errorf("%+v: with the index %q, without %q", s, with, without)
+		}
+	}
+	if !narrowed {
+		t.Error("the index never ruled out a file")
+	}
+}
+
+// lines runs q and returns its matches as sorted "file:line" strings
+func lines(q Query) ([]string, Stats) {
+	var found []string
+	stats := Run(context.Background(), q, func(matches []Match) {
+		for _, m := range matches {
+			found = append(found, fmt.Sprintf("%s:%d", m.File, m.Line))
+		}
+	})
+	sort.Strings(found)
+	return found, stats
+}
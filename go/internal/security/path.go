@@ -1,22 +1,131 @@
 package security
 
 import (
+	"errors"
+	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/c00d-ide/c00d/internal/config"
 )
 
-// ValidatePath checks if a path is within the allowed base path
-func ValidatePath(path string) (string, bool) {
-	fullPath := filepath.Join(config.C.BasePath, path)
-	if !strings.HasPrefix(fullPath, config.C.BasePath) {
-		return "", false
+var (
+	// ErrOutside is returned for paths that lead outside the root
+	ErrOutside = errors.New("path is outside the base path")
+	// ErrTooManyLinks is returned for symlink loops
+	ErrTooManyLinks = errors.New("too many levels of symbolic links")
+)
+
+// maxLinks is how many symlinks one path may go through, as in Linux
+const maxLinks = 40
+
+// Resolve joins path to root and checks the result with Check. The joined
+// path is returned, not the resolved one, so that it names what the client
+// asked for.
+func Resolve(root, path string, follow bool) (string, error) {
+	fullPath := filepath.Join(root, filepath.FromSlash(path))
+	return fullPath, Check(root, fullPath, follow)
+}
+
+// Check checks that fullPath is within root. Paths are compared by whole
+// components, so "/srv/app2" is not inside "/srv/app".
+//
+// Unless security.follow_external_symlinks is set, symlinks must not lead
+// outside root either. The path is walked a component at a time from root
+// and every symlink met is resolved and must stay within root, even if a
+// later ".." would come back. Components that don't exist are taken as they
+// are, so paths about to be created can be checked too. If follow is false,
+// a symlink in the last component is not resolved.
+//
+// This is a check, not a way to open files: callers open the path again
+// afterwards and the OS resolves it anew. A symlink swapped into the path
+// between the two by someone who can write to the workspace isn't caught.
+func Check(root, fullPath string, follow bool) error {
+	root = filepath.Clean(root)
+	if !WithinDir(root, fullPath) {
+		return ErrOutside
+	}
+	if config.C.Security.FollowExternalSymlinks {
+		return nil
+	}
+
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return err
 	}
-	return fullPath, true
+	rel, _ := filepath.Rel(root, filepath.Clean(fullPath))
+	rest := splitPath(rel)
+
+	cur := realRoot
+	links := 0
+	for len(rest) > 0 {
+		name := rest[0]
+		rest = rest[1:]
+
+		switch name {
+		case ".", "":
+			continue
+		case "..": // Only symlink targets still contain these
+			cur = filepath.Dir(cur)
+			if !WithinDir(realRoot, cur) {
+				return ErrOutside
+			}
+			continue
+		}
+
+		next := filepath.Join(cur, name)
+		if len(rest) == 0 && !follow {
+			return nil
+		}
+		info, err := os.Lstat(next)
+		if err != nil {
+			// Nothing more to resolve; what's left is taken literally
+			if !WithinDir(realRoot, filepath.Join(append([]string{next}, rest...)...)) {
+				return ErrOutside
+			}
+			return nil
+		}
+		if info.Mode()&os.ModeSymlink == 0 {
+			cur = next
+			continue
+		}
+
+		links++
+		if links > maxLinks {
+			return ErrTooManyLinks
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return err
+		}
+		if filepath.IsAbs(target) {
+			// Absolute targets may name the root by either of its paths
+			base := realRoot
+			if !WithinDir(realRoot, target) {
+				base = root
+			}
+			if !WithinDir(base, target) {
+				return ErrOutside
+			}
+			targetRel, _ := filepath.Rel(base, target)
+			cur, target = realRoot, targetRel
+		}
+		rest = append(splitPath(target), rest...)
+	}
+	return nil
+}
+
+// splitPath splits a relative path into its components
+func splitPath(path string) []string {
+	return strings.Split(filepath.ToSlash(path), "/")
 }
 
-// ValidateFullPath checks if an absolute path is within the allowed base path
-func ValidateFullPath(fullPath string) bool {
-	return strings.HasPrefix(fullPath, config.C.BasePath)
+// WithinDir checks if fullPath is dir itself or inside it, comparing whole
+// path components so "/srv/app2" is not inside "/srv/app"
+func WithinDir(dir, fullPath string) bool {
+	rel, err := filepath.Rel(dir, fullPath)
+	if err != nil || filepath.IsAbs(rel) {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
 }
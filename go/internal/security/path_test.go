@@ -0,0 +1,139 @@
+package security
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setup creates a root at <tmp>/srv/app with a sibling <tmp>/srv/app2 and
+// a directory <tmp>/outside, and returns the root and the temp dir
+func setup(t *testing.T) (root, tmp string) {
+	t.Helper()
+	tmp = t.TempDir()
+	root = filepath.Join(tmp, "srv", "app")
+	for _, dir := range []string{root, filepath.Join(root, "sub"), filepath.Join(tmp, "srv", "app2"), filepath.Join(tmp, "outside")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root, tmp
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckSiblingPrefix(t *testing.T) {
+	root, tmp := setup(t)
+	if err := Check(root, filepath.Join(tmp, "srv", "app2", "secret"), true); !errors.Is(err, ErrOutside) {
+		t.Errorf("app2 inside app: %v", err)
+	}
+	if WithinDir(root, root+"2") {
+		t.Error("WithinDir matched by prefix")
+	}
+}
+
+func TestCheckTraversal(t *testing.T) {
+	root, _ := setup(t)
+	if _, err := Resolve(root, "../../outside/secret", true); !errors.Is(err, ErrOutside) {
+		t.Errorf("../../outside: %v", err)
+	}
+	if _, err := Resolve(root, "sub/../../app2", true); !errors.Is(err, ErrOutside) {
+		t.Errorf("sub/../../app2: %v", err)
+	}
+	if _, err := Resolve(root, "sub/../file", true); err != nil {
+		t.Errorf("sub/../file: %v", err)
+	}
+}
+
+func TestCheckAbsoluteSymlinkOut(t *testing.T) {
+	root, tmp := setup(t)
+	symlink(t, filepath.Join(tmp, "outside"), filepath.Join(root, "abs"))
+	if err := Check(root, filepath.Join(root, "abs", "secret"), true); !errors.Is(err, ErrOutside) {
+		t.Errorf("abs/secret: %v", err)
+	}
+
+	// An absolute link back into the root is fine
+	symlink(t, filepath.Join(root, "sub"), filepath.Join(root, "back"))
+	if err := Check(root, filepath.Join(root, "back", "file"), true); err != nil {
+		t.Errorf("back/file: %v", err)
+	}
+}
+
+func TestCheckRelativeSymlinkOut(t *testing.T) {
+	root, _ := setup(t)
+	symlink(t, "../../outside", filepath.Join(root, "rel"))
+	if err := Check(root, filepath.Join(root, "rel", "secret"), true); !errors.Is(err, ErrOutside) {
+		t.Errorf("rel/secret: %v", err)
+	}
+
+	// Leaving through a symlink fails even if a later ".." would come back
+	symlink(t, "..", filepath.Join(root, "up"))
+	if err := Check(root, filepath.Join(root, "up", "app", "file"), true); !errors.Is(err, ErrOutside) {
+		t.Errorf("up/app/file: %v", err)
+	}
+}
+
+func TestCheckMultiHopChain(t *testing.T) {
+	root, _ := setup(t)
+	symlink(t, "l2", filepath.Join(root, "l1"))
+	symlink(t, "sub/l3", filepath.Join(root, "l2"))
+	symlink(t, "../../../outside", filepath.Join(root, "sub", "l3"))
+	if err := Check(root, filepath.Join(root, "l1", "secret"), true); !errors.Is(err, ErrOutside) {
+		t.Errorf("l1 -> l2 -> sub/l3 -> outside: %v", err)
+	}
+
+	// Each hop of this chain climbs one level and stays inside the root
+	symlink(t, "..", filepath.Join(root, "sub", "s1"))
+	if err := Check(root, filepath.Join(root, "sub", "s1", "sub", "s1", "file"), true); err != nil {
+		t.Errorf("sub/s1/sub/s1/file: %v", err)
+	}
+	symlink(t, "s1/..", filepath.Join(root, "sub", "s2"))
+	if err := Check(root, filepath.Join(root, "sub", "s2", "file"), true); !errors.Is(err, ErrOutside) {
+		t.Errorf("sub/s2 -> s1/.. -> parent of root: %v", err)
+	}
+}
+
+func TestCheckSymlinkLoop(t *testing.T) {
+	root, _ := setup(t)
+	symlink(t, "loop2", filepath.Join(root, "loop1"))
+	symlink(t, "loop1", filepath.Join(root, "loop2"))
+	if err := Check(root, filepath.Join(root, "loop1", "file"), true); !errors.Is(err, ErrTooManyLinks) {
+		t.Errorf("loop: %v", err)
+	}
+}
+
+func TestCheckMissingTail(t *testing.T) {
+	root, _ := setup(t)
+	if err := Check(root, filepath.Join(root, "new", "dir", "file.txt"), true); err != nil {
+		t.Errorf("new/dir/file.txt: %v", err)
+	}
+
+	// The part that exists is still resolved
+	symlink(t, "../../outside", filepath.Join(root, "rel"))
+	if err := Check(root, filepath.Join(root, "rel", "new", "file.txt"), true); !errors.Is(err, ErrOutside) {
+		t.Errorf("rel/new/file.txt: %v", err)
+	}
+}
+
+func TestCheckNoFollow(t *testing.T) {
+	root, tmp := setup(t)
+	link := filepath.Join(root, "abs")
+	symlink(t, filepath.Join(tmp, "outside"), link)
+
+	// The link itself can be deleted or renamed, but not followed
+	if err := Check(root, link, false); err != nil {
+		t.Errorf("follow=false: %v", err)
+	}
+	if err := Check(root, link, true); !errors.Is(err, ErrOutside) {
+		t.Errorf("follow=true: %v", err)
+	}
+	if err := Check(root, filepath.Join(link, "secret"), false); !errors.Is(err, ErrOutside) {
+		t.Errorf("follow=false below the link: %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package symbols
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+)
+
+// parseGo returns the top-level declarations of a Go file, with the
+// fields and methods of its structs and interfaces. Files with syntax
+// errors give what could be parsed.
+func parseGo(content []byte) []Symbol {
+	fset := token.NewFileSet()
+	f, _ := parser.ParseFile(fset, "", content, parser.SkipObjectResolution)
+	if f == nil {
+		return nil
+	}
+
+	var symbols []Symbol
+	add := func(name *ast.Ident, kind, container string, node ast.Node) {
+		if name == nil || name.Name == "_" || !name.Pos().IsValid() {
+			return
+		}
+		// Positions as in the file, not as //line directives say
+		pos := fset.PositionFor(name.Pos(), false)
+		lineStart := bytes.LastIndexByte(content[:pos.Offset], '\n') + 1
+		symbols = append(symbols, Symbol{
+			Name:      name.Name,
+			Kind:      kind,
+			Container: container,
+			Line:      pos.Line,
+			Column:    column(content[lineStart:], pos.Offset-lineStart),
+			EndLine:   fset.PositionFor(node.End(), false).Line,
+		})
+	}
+
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				add(d.Name, KindMethod, typeIdent(d.Recv.List[0].Type).Name, d)
+			} else {
+				add(d.Name, KindFunction, "", d)
+			}
+
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				// A declaration on its own spans from its keyword
+				var node ast.Node = spec
+				if !d.Lparen.IsValid() {
+					node = d
+				}
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					switch t := s.Type.(type) {
+					case *ast.StructType:
+						add(s.Name, KindStruct, "", node)
+						for _, field := range t.Fields.List {
+							if len(field.Names) == 0 {
+								add(typeIdent(field.Type), KindField, s.Name.Name, field) // Embedded
+							}
+							for _, name := range field.Names {
+								add(name, KindField, s.Name.Name, field)
+							}
+						}
+					case *ast.InterfaceType:
+						add(s.Name, KindInterface, "", node)
+						for _, method := range t.Methods.List {
+							for _, name := range method.Names {
+								add(name, KindMethod, s.Name.Name, method)
+							}
+						}
+					default:
+						add(s.Name, KindType, "", node)
+					}
+				case *ast.ValueSpec:
+					kind := KindVariable
+					if d.Tok == token.CONST {
+						kind = KindConstant
+					}
+					for _, name := range s.Names {
+						add(name, kind, "", node)
+					}
+				}
+			}
+		}
+	}
+	return symbols
+}
+
+// typeIdent returns the name of the type a receiver or embedded field
+// refers to, without pointers, packages or type parameters
+func typeIdent(expr ast.Expr) *ast.Ident {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		return t
+	case *ast.StarExpr:
+		return typeIdent(t.X)
+	case *ast.ParenExpr:
+		return typeIdent(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel
+	case *ast.IndexExpr:
+		return typeIdent(t.X)
+	case *ast.IndexListExpr:
+		return typeIdent(t.X)
+	}
+	return &ast.Ident{}
+}
@@ -0,0 +1,379 @@
+package symbols
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+// kindScope marks declarations that only give their name to what they
+// contain, like Rust impl blocks, and aren't symbols themselves
+const kindScope = "scope"
+
+// grammar finds the declarations of a language, ctags style: each line is
+// matched against patterns whose first group is the declared name
+type grammar struct {
+	rules     []rule
+	braces    bool     // Blocks are in braces, rather than only indented
+	qualified []string // Separators in names like Type::method, naming the container
+}
+
+type rule struct {
+	kind    string
+	re      *regexp.Regexp
+	inClass bool // Only a declaration inside a class, struct or the like
+}
+
+func r(kind, expr string) rule {
+	return rule{kind: kind, re: regexp.MustCompile(expr)}
+}
+
+// member matches only inside a class, where the pattern would otherwise
+// match ordinary statements
+func member(kind, expr string) rule {
+	return rule{kind: kind, re: regexp.MustCompile(expr), inClass: true}
+}
+
+// statements start lines that never declare anything, though a pattern
+// might take them for a declaration
+var statements = map[string]bool{
+	"if": true, "else": true, "for": true, "foreach": true, "while": true, "do": true,
+	"switch": true, "case": true, "catch": true, "return": true, "throw": true,
+	"new": true, "await": true, "yield": true, "delete": true, "typeof": true,
+	"sizeof": true, "echo": true, "print": true, "goto": true, "using": true,
+}
+
+// keywords are never the names of declarations, though patterns for
+// methods match control statements like "if (x) {"
+var keywords = map[string]bool{
+	"if": true, "else": true, "for": true, "foreach": true, "while": true, "do": true,
+	"switch": true, "case": true, "catch": true, "return": true, "throw": true,
+	"sizeof": true, "typeof": true, "function": true,
+}
+
+const jsName = `([A-Za-z_$][\w$]*)`
+
+var (
+	jsRules = []rule{
+		r(KindFunction, `^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*`+jsName),
+		r(KindClass, `^\s*(?:export\s+)?(?:default\s+)?(?:abstract\s+)?class\s+`+jsName),
+		r(KindFunction, `^\s*(?:export\s+)?(?:const|let|var)\s+`+jsName+`\s*=\s*(?:async\s+)?(?:function\b|\([^)]*\)\s*(?::[^=]+)?=>|[A-Za-z_$][\w$]*\s*=>)`),
+		member(KindMethod, `^\s+(?:(?:public|private|protected|static|async|readonly|override|abstract|get|set)\s+)*\*?#?`+jsName+`\s*(?:<[^>]*>)?\([^)]*\)?\s*(?::\s*[^{]+)?\{?\s*$`),
+	}
+	tsRules = append([]rule{
+		r(KindInterface, `^\s*(?:export\s+)?(?:declare\s+)?interface\s+`+jsName),
+		r(KindType, `^\s*(?:export\s+)?(?:declare\s+)?type\s+`+jsName+`\s*(?:<[^=]*>)?\s*=`),
+		r(KindEnum, `^\s*(?:export\s+)?(?:declare\s+)?(?:const\s+)?enum\s+`+jsName),
+		r(KindModule, `^\s*(?:export\s+)?(?:declare\s+)?namespace\s+([\w.]+)`),
+	}, jsRules...)
+
+	javaModifiers = `(?:(?:public|protected|private|internal|static|final|abstract|sealed|non-sealed|strictfp|open|data|inner|synchronized|native|default|override|suspend|inline|private\s*\(set\))\s+|@\w+(?:\([^)]*\))?\s+)*`
+
+	grammars = map[string]*grammar{
+		"javascript": {rules: jsRules, braces: true},
+		"typescript": {rules: tsRules, braces: true},
+		"vue":        {rules: tsRules, braces: true},
+		"svelte":     {rules: tsRules, braces: true},
+
+		"python": {rules: []rule{
+			r(KindFunction, `^\s*(?:async\s+)?def\s+(\w+)`),
+			r(KindClass, `^\s*class\s+(\w+)`),
+		}},
+
+		"php": {rules: []rule{
+			r(KindFunction, `^\s*(?:(?:abstract|final|public|private|protected|static)\s+)*function\s+&?(\w+)`),
+			r(KindClass, `^\s*(?:(?:abstract|final|readonly)\s+)*(?:class|trait)\s+(\w+)`),
+			r(KindInterface, `^\s*interface\s+(\w+)`),
+			r(KindEnum, `^\s*enum\s+(\w+)`),
+			r(KindConstant, `^\s*(?:(?:public|private|protected|final)\s+)*const\s+(\w+)\s*=`),
+		}, braces: true},
+
+		"rust": {rules: []rule{
+			r(KindFunction, `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:(?:const|async|unsafe|extern(?:\s+"[^"]*")?)\s+)*fn\s+(\w+)`),
+			r(KindStruct, `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:struct|union)\s+(\w+)`),
+			r(KindEnum, `^\s*(?:pub(?:\([^)]*\))?\s+)?enum\s+(\w+)`),
+			r(KindInterface, `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:unsafe\s+)?trait\s+(\w+)`),
+			r(KindType, `^\s*(?:pub(?:\([^)]*\))?\s+)?type\s+(\w+)`),
+			r(KindModule, `^\s*(?:pub(?:\([^)]*\))?\s+)?mod\s+(\w+)\s*\{`),
+			r(KindConstant, `^\s*(?:pub(?:\([^)]*\))?\s+)?(?:const|static)\s+(?:mut\s+)?(\w+)\s*:`),
+			r(KindVariable, `^(?:pub(?:\([^)]*\))?\s+)?static\s+(?:mut\s+)?(\w+)`),
+			r(kindScope, `^\s*(?:unsafe\s+)?impl(?:\s*<[^>]*>)?\s+(?:[\w:]+(?:<[^>]*>)?\s+for\s+)?(?:[\w]+::)*(\w+)`),
+			r(KindFunction, `^\s*macro_rules!\s*(\w+)`),
+		}, braces: true},
+
+		"java": {rules: []rule{
+			r(KindClass, `^\s*`+javaModifiers+`(?:class|record)\s+(\w+)`),
+			r(KindInterface, `^\s*`+javaModifiers+`@?interface\s+(\w+)`),
+			r(KindEnum, `^\s*`+javaModifiers+`enum\s+(\w+)`),
+			member(KindMethod, `^\s+`+javaModifiers+`(?:<[^>]+>\s+)?[\w.<>\[\]?, ]+?\s+(\w+)\s*\([^;=]*$`),
+			member(KindMethod, `^\s+`+javaModifiers+`(?:<[^>]+>\s+)?[\w.<>\[\]?, ]+?\s+(\w+)\s*\([^=]*\)\s*(?:throws [\w., ]+)?;`),
+		}, braces: true},
+
+		"kotlin": {rules: []rule{
+			r(KindFunction, `^\s*`+javaModifiers+`fun\s+(?:<[^>]+>\s+)?(?:[\w.<>?]+\.)?(\w+)\s*\(`),
+			r(KindInterface, `^\s*`+javaModifiers+`(?:fun\s+)?interface\s+(\w+)`),
+			r(KindEnum, `^\s*`+javaModifiers+`enum\s+class\s+(\w+)`),
+			r(KindClass, `^\s*`+javaModifiers+`(?:class|object)\s+(\w+)`),
+			r(KindType, `^\s*`+javaModifiers+`typealias\s+(\w+)`),
+			r(KindConstant, `^\s*`+javaModifiers+`const\s+val\s+(\w+)`),
+		}, braces: true},
+
+		"swift": {rules: []rule{
+			r(KindFunction, `^\s*`+javaModifiers+`(?:mutating\s+|class\s+)?func\s+(\w+)`),
+			r(KindClass, `^\s*`+javaModifiers+`(?:final\s+)?(?:class|actor)\s+(\w+)`),
+			r(KindStruct, `^\s*`+javaModifiers+`struct\s+(\w+)`),
+			r(KindEnum, `^\s*`+javaModifiers+`(?:indirect\s+)?enum\s+(\w+)`),
+			r(KindInterface, `^\s*`+javaModifiers+`protocol\s+(\w+)`),
+			r(KindType, `^\s*`+javaModifiers+`typealias\s+(\w+)`),
+			r(kindScope, `^\s*`+javaModifiers+`extension\s+(?:\w+\.)*(\w+)`),
+		}, braces: true},
+
+		"c": {rules: cRules, braces: true},
+		"cpp": {rules: append([]rule{
+			r(KindClass, `^\s*(?:template\s*<[^>]*>\s*)?class\s+(?:\w+\s+)*?(\w+)\s*(?:final\s*)?(?:[:{]|$)`),
+			r(KindModule, `^\s*namespace\s+([\w:]+)\s*\{?`),
+			member(KindMethod, `^\s+(?:(?:virtual|static|inline|explicit|constexpr|friend)\s+)*(?:[\w:<>,*&]+\s+)*[*&]*(~?\w+)\s*\([^;]*\)\s*(?:const\s*)?(?:override\s*)?(?:final\s*)?(?:noexcept\s*)?(?:=\s*\w+\s*)?[;{]?\s*$`),
+		}, cRules...), braces: true, qualified: []string{"::"}},
+
+		"ruby": {rules: []rule{
+			r(KindFunction, `^\s*def\s+(?:self\.)?(\w+[?!=]?)`),
+			r(KindClass, `^\s*class\s+(?:\w+::)*(\w+)`),
+			r(KindModule, `^\s*module\s+(?:\w+::)*(\w+)`),
+		}},
+
+		"lua": {rules: []rule{
+			r(KindFunction, `^\s*(?:local\s+)?function\s+([\w.:]+)`),
+			r(KindFunction, `^\s*(?:local\s+)?([\w.]+)\s*=\s*function\b`),
+		}, qualified: []string{":", "."}},
+
+		"shell": {rules: []rule{
+			r(KindFunction, `^\s*function\s+([\w.:-]+)`),
+			r(KindFunction, `^\s*([A-Za-z_][\w.:-]*)\s*\(\s*\)`),
+		}, braces: true},
+	}
+)
+
+var cRules = []rule{
+	r(KindStruct, `^\s*(?:typedef\s+)?(?:struct|union)\s+(\w+)\s*\{?\s*$`),
+	r(KindEnum, `^\s*(?:typedef\s+)?enum\s+(?:class\s+)?(\w+)(?:\s*:\s*\w+)?\s*\{?\s*$`),
+	r(KindConstant, `^#\s*define\s+(\w+)`),
+	r(KindType, `^\s*typedef\s+[^;(]*?\b(\w+)\s*;`),
+	r(KindFunction, `^(?:[\w*&:<>,]+\s+)+[*&]*(~?[A-Za-z_][\w:~]*)\s*\([^;]*$`),
+}
+
+// isScope reports whether symbols of kind can contain others
+func isScope(kind string) bool {
+	switch kind {
+	case KindClass, KindStruct, KindInterface, KindEnum, KindModule, kindScope:
+		return true
+	}
+	return false
+}
+
+// parse finds the declarations in content and works out where each ends
+// and what contains it
+func (g *grammar) parse(content []byte) []Symbol {
+	type found struct {
+		Symbol
+		inClass bool
+	}
+
+	lines := splitLines(content)
+	var all []found
+	for i, line := range lines {
+		if first := firstWord(line); statements[first] {
+			continue
+		}
+		for _, rule := range g.rules {
+			m := rule.re.FindSubmatchIndex(line)
+			if m == nil || m[2] < 0 {
+				continue
+			}
+			name := string(line[m[2]:m[3]])
+			if keywords[name] {
+				continue
+			}
+			s := found{Symbol: Symbol{
+				Name:    name,
+				Kind:    rule.kind,
+				Line:    i + 1,
+				Column:  column(line, m[2]),
+				EndLine: g.blockEnd(lines, i) + 1,
+			}, inClass: rule.inClass}
+			// Type::method names its container
+			for _, sep := range g.qualified {
+				if at := strings.LastIndex(name, sep); at > 0 && at+len(sep) < len(name) {
+					s.Container, s.Name = name[:at], name[at+len(sep):]
+					s.Column += len(name[:at+len(sep)])
+					if s.Kind == KindFunction {
+						s.Kind = KindMethod
+					}
+					break
+				}
+			}
+			all = append(all, s)
+			break
+		}
+	}
+
+	// Nest declarations in the scopes spanning them
+	symbols := []Symbol{}
+	var open []Symbol
+	for _, s := range all {
+		for len(open) > 0 && open[len(open)-1].EndLine < s.Line {
+			open = open[:len(open)-1]
+		}
+		if len(open) > 0 {
+			scope := open[len(open)-1]
+			if s.Container == "" {
+				s.Container = scope.Name
+			}
+			if s.Kind == KindFunction && scope.Kind != KindModule {
+				s.Kind = KindMethod
+			}
+		} else if s.inClass {
+			continue
+		}
+		if isScope(s.Kind) {
+			open = append(open, s.Symbol)
+		}
+		if s.Kind != kindScope {
+			symbols = append(symbols, s.Symbol)
+		}
+	}
+	return symbols
+}
+
+// blockEnd returns the index of the last line of the definition starting
+// at lines[start]
+func (g *grammar) blockEnd(lines [][]byte, start int) int {
+	if g.braces {
+		if end, ok := braceEnd(lines, start); ok {
+			return end
+		}
+	}
+	return indentEnd(lines, start)
+}
+
+// braceEnd returns the index of the line closing the brace the definition
+// at lines[start] opens, skipping braces in strings and comments. ok is
+// false if no brace opens before a line indented no further than the
+// definition, which would be its body.
+func braceEnd(lines [][]byte, start int) (end int, ok bool) {
+	base := indent(lines[start])
+	depth, opened, comment := 0, false, false
+	for i := start; i < len(lines); i++ {
+		line := lines[i]
+		if i > start && !opened {
+			text := bytes.TrimSpace(line)
+			if len(text) > 0 && indent(line) <= base && text[0] != '{' && text[0] != ')' {
+				return 0, false
+			}
+		}
+		for j := 0; j < len(line); j++ {
+			c := line[j]
+			next := byte(0)
+			if j+1 < len(line) {
+				next = line[j+1]
+			}
+			switch {
+			case comment:
+				if c == '*' && next == '/' {
+					comment = false
+					j++
+				}
+			case c == '/' && next == '/':
+				j = len(line)
+			case c == '/' && next == '*':
+				comment = true
+				j++
+			case c == '"' || c == '\'':
+				// Quotes without a match on the line, like Rust lifetimes, are left be
+				if k := closingQuote(line, j); k > 0 {
+					j = k
+				}
+			case c == ';' && !opened:
+				return i, true // A declaration without a body
+			case c == '{':
+				depth++
+				opened = true
+			case c == '}':
+				depth--
+				if opened && depth == 0 {
+					return i, true
+				}
+			}
+		}
+	}
+	return len(lines) - 1, opened
+}
+
+// closingQuote returns the index of the quote ending the string starting
+// at line[start], or -1
+func closingQuote(line []byte, start int) int {
+	for i := start + 1; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case line[start]:
+			return i
+		}
+	}
+	return -1
+}
+
+// indentEnd returns the index of the last line of the definition starting
+// at lines[start] from indentation: the lines indented further than it,
+// and a closing bracket or "end" in line with it. Comments are passed
+// over wherever they are.
+func indentEnd(lines [][]byte, start int) int {
+	base := indent(lines[start])
+	end := start
+	for i := start + 1; i < len(lines); i++ {
+		text := bytes.TrimSpace(lines[i])
+		if len(text) == 0 || isComment(text) {
+			continue
+		}
+		if indent(lines[i]) > base {
+			end = i
+			continue
+		}
+		if indent(lines[i]) < base {
+			break
+		}
+		switch {
+		case text[0] == '{' || text[0] == ')' || text[0] == ']':
+			// An opening brace on its own line, or the end of a long signature
+			end = i
+			continue
+		case text[0] == '}' || isEnd(text):
+			end = i
+		}
+		break
+	}
+	return end
+}
+
+// isComment reports whether a line, without its indentation, is a comment
+func isComment(text []byte) bool {
+	return text[0] == '#' || bytes.HasPrefix(text, []byte("--")) || bytes.HasPrefix(text, []byte("//"))
+}
+
+// isEnd reports whether a line starts with the "end" keyword closing a
+// block in Ruby or Lua
+func isEnd(text []byte) bool {
+	if !bytes.HasPrefix(text, []byte("end")) {
+		return false
+	}
+	return len(text) == 3 || (!isIdent(text[3]) && !bytes.Contains(text, []byte("=")))
+}
+
+// firstWord returns the identifier a line starts with, after indentation
+func firstWord(line []byte) string {
+	line = bytes.TrimLeft(line, " \t")
+	n := 0
+	for n < len(line) && isIdent(line[n]) {
+		n++
+	}
+	return string(line[:n])
+}
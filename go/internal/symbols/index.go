@@ -0,0 +1,223 @@
+package symbols
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/c00d-ide/c00d/internal/filetree"
+	"github.com/c00d-ide/c00d/internal/fileutil"
+	"github.com/c00d-ide/c00d/internal/fuzzy"
+	"github.com/c00d-ide/c00d/internal/language"
+	"github.com/c00d-ide/c00d/internal/security"
+)
+
+// maxFileSize is the largest file symbols are looked for in
+const maxFileSize = 1024 * 1024
+
+// Index caches the symbols of the files in a workspace. A file is parsed
+// again only when its size or modification time changes.
+type Index struct {
+	Root string
+
+	mu    sync.Mutex
+	files map[string]*cachedFile // Relative path -> symbols
+}
+
+type cachedFile struct {
+	size    int64
+	modTime time.Time
+	symbols []Symbol
+}
+
+var (
+	indexesMu sync.Mutex
+	indexes   = map[string]*Index{}
+)
+
+// For returns the symbol index of root. Files are parsed when first
+// searched.
+func For(root string) *Index {
+	indexesMu.Lock()
+	defer indexesMu.Unlock()
+
+	if ix, ok := indexes[root]; ok {
+		return ix
+	}
+	ix := &Index{Root: root, files: map[string]*cachedFile{}}
+	indexes[root] = ix
+	return ix
+}
+
+// File returns the symbols of the file at rel, a slash-separated path
+// relative to the root
+func (ix *Index) File(rel string) ([]Symbol, error) {
+	symbols, err := ix.symbols(rel)
+	if symbols == nil {
+		symbols = []Symbol{}
+	}
+	return symbols, err
+}
+
+// symbols returns the symbols of a file, from the cache while it's
+// unchanged. Files too large or binary have none, and symlinks leading
+// outside the workspace aren't read.
+func (ix *Index) symbols(rel string) ([]Symbol, error) {
+	fullPath := filepath.Join(ix.Root, filepath.FromSlash(rel))
+	if err := security.Check(ix.Root, fullPath, true); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(fullPath)
+	if err != nil {
+		return nil, err
+	}
+
+	ix.mu.Lock()
+	cached := ix.files[rel]
+	ix.mu.Unlock()
+	if cached != nil && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+		return cached.symbols, nil
+	}
+
+	var symbols []Symbol
+	if info.Mode().IsRegular() && info.Size() <= maxFileSize {
+		content, err := os.ReadFile(fullPath)
+		if err != nil {
+			return nil, err
+		}
+		if !fileutil.IsBinary(content) {
+			symbols = Parse(rel, content)
+		}
+	}
+
+	ix.mu.Lock()
+	ix.files[rel] = &cachedFile{size: info.Size(), modTime: info.ModTime(), symbols: symbols}
+	ix.mu.Unlock()
+	return symbols, nil
+}
+
+// Match is a symbol matching a query
+type Match struct {
+	Symbol
+	Score     int   `json:"score"`
+	Positions []int `json:"positions"` // Byte offsets of matched characters in the name
+}
+
+// Search ranks the symbols of every file in the workspace against query,
+// fuzzily and ignoring case, and returns the best limit matches and how
+// many symbols were searched. A query with a dot, like "Server.Start", is
+// matched against the container and name. Files that changed since they
+// were last searched are parsed first, on a pool of workers.
+func (ix *Index) Search(ctx context.Context, query string, limit int) ([]Match, int) {
+	query = strings.ToLower(strings.ReplaceAll(query, " ", ""))
+	qualified := strings.Contains(query, ".")
+
+	var paths []string
+	for _, rel := range filetree.For(ix.Root).Files() {
+		if Supported(language.Detect(rel)) {
+			paths = append(paths, rel)
+		}
+	}
+	ix.forget(paths)
+
+	// Parse on a pool of workers; each writes only its own files' slots
+	perFile := make([][]Symbol, len(paths))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for i := 0; i < max(runtime.NumCPU(), 4); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				perFile[i], _ = ix.symbols(paths[i])
+			}
+		}()
+	}
+	for i := range paths {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	matches := []Match{}
+	total := 0
+	for i, symbols := range perFile {
+		total += len(symbols)
+		if query == "" {
+			continue
+		}
+		for _, s := range symbols {
+			candidate, prefix := s.Name, 0
+			if qualified && s.Container != "" {
+				candidate = s.Container + "." + s.Name
+				prefix = len(s.Container) + 1
+			}
+			score, positions, ok := fuzzy.Score(query, candidate)
+			if !ok {
+				continue
+			}
+
+			// Positions in the name only; favour whole and leading matches
+			inName := []int{}
+			for _, p := range positions {
+				if p >= prefix {
+					inName = append(inName, p-prefix)
+				}
+			}
+			name := strings.ToLower(s.Name)
+			switch {
+			case name == query || strings.ToLower(candidate) == query:
+				score += 100
+			case strings.HasPrefix(name, query):
+				score += 30
+			}
+			if s.Kind == KindField || s.Kind == KindVariable {
+				score -= 10
+			}
+
+			s.File = paths[i]
+			matches = append(matches, Match{Symbol: s, Score: score, Positions: inName})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if len(a.Name) != len(b.Name) {
+			return len(a.Name) < len(b.Name)
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+	return matches, total
+}
+
+// forget drops cached files no longer among paths
+func (ix *Index) forget(paths []string) {
+	keep := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		keep[p] = true
+	}
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	for rel := range ix.files {
+		if !keep[rel] {
+			delete(ix.files, rel)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package symbols
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"github.com/c00d-ide/c00d/internal/language"
+)
+
+// Symbol kinds
+const (
+	KindFunction  = "function"
+	KindMethod    = "method"
+	KindClass     = "class"
+	KindStruct    = "struct"
+	KindInterface = "interface"
+	KindEnum      = "enum"
+	KindType      = "type"
+	KindField     = "field"
+	KindConstant  = "constant"
+	KindVariable  = "variable"
+	KindModule    = "module"
+)
+
+// Symbol is a named definition in a file
+type Symbol struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Container string `json:"container,omitempty"` // Type or class it belongs to
+	File      string `json:"file,omitempty"`      // Set in workspace results
+	Line      int    `json:"line"`                // Line of the name
+	Column    int    `json:"column"`              // 1-based, in UTF-16 code units like the editor
+	EndLine   int    `json:"end_line"`            // Last line of the definition
+}
+
+// Supported reports whether symbols can be found in files of lang, a
+// language id from the language package
+func Supported(lang string) bool {
+	return lang == "go" || grammars[lang] != nil
+}
+
+// Parse returns the symbols defined in the content of a file, in the
+// order they appear. Go is parsed properly; other languages are matched
+// line by line against patterns for their declarations, so they may miss
+// unusual code, and their end lines are inferred from indentation.
+func Parse(filename string, content []byte) []Symbol {
+	lang := language.Detect(filename)
+	if lang == "go" {
+		return parseGo(content)
+	}
+	if g := grammars[lang]; g != nil {
+		return g.parse(content)
+	}
+	return nil
+}
+
+// Enclosing returns the symbols whose definitions span line, outermost
+// first, as an editor breadcrumb shows them
+func Enclosing(symbols []Symbol, line int) []Symbol {
+	path := []Symbol{}
+	for _, s := range symbols {
+		if s.Line <= line && line <= s.EndLine {
+			path = append(path, s)
+		}
+	}
+	return path
+}
+
+// column returns the column of the byte at offset in line
+func column(line []byte, offset int) int {
+	col := 1
+	for _, r := range string(line[:offset]) {
+		col++
+		if r >= 0x10000 {
+			col++ // Surrogate pair
+		}
+	}
+	return col
+}
+
+// splitLines splits content into lines without their line endings
+func splitLines(content []byte) [][]byte {
+	lines := bytes.Split(content, []byte("\n"))
+	if len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	for i, line := range lines {
+		lines[i] = bytes.TrimSuffix(line, []byte("\r"))
+	}
+	return lines
+}
+
+// indent returns the width of the leading whitespace of line, with tabs
+// moving to the next multiple of 8
+func indent(line []byte) int {
+	width := 0
+	for _, b := range line {
+		switch b {
+		case ' ':
+			width++
+		case '\t':
+			width += 8 - width%8
+		default:
+			return width
+		}
+	}
+	return width
+}
+
+// isIdent reports whether b can be part of an identifier
+func isIdent(b byte) bool {
+	return b == '_' || b == '$' || b >= utf8.RuneSelf || (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || (b >= '0' && b <= '9')
+}
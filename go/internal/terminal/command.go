@@ -0,0 +1,189 @@
+package terminal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// ErrCommandNotFound is returned when a command ID doesn't match a running command
+var ErrCommandNotFound = errors.New("command not found")
+
+// Reasons a command was killed
+const (
+	KillCanceled    = "canceled"
+	KillTimeout     = "timeout"
+	KillOutputLimit = "output_limit"
+)
+
+// Limits bounds the resources a command may use. Zero values mean no limit.
+type Limits struct {
+	Timeout     time.Duration
+	MaxOutput   int64
+	CPUSeconds  int
+	MemoryBytes int64
+}
+
+// Command is a one-shot shell command started through the terminal API
+type Command struct {
+	ID string
+
+	cmd    *exec.Cmd
+	limits Limits
+	timer  *time.Timer
+
+	mu         sync.Mutex
+	killReason string
+	written    int64
+	truncated  bool
+}
+
+var (
+	commandsMu sync.Mutex
+	commands   = map[string]*Command{}
+)
+
+// NewCommand prepares command to run through the shell in dir, in its own
+// process group so it can be killed along with any children it forks
+func NewCommand(command, dir string, limits Limits) *Command {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", withRlimits(command, limits))
+	}
+	cmd.Dir = dir
+	setProcessGroup(cmd)
+
+	// Don't hang on background jobs that keep the output pipes open
+	cmd.WaitDelay = 2 * time.Second
+
+	token := make([]byte, 8)
+	rand.Read(token)
+	return &Command{ID: hex.EncodeToString(token), cmd: cmd, limits: limits}
+}
+
+// Start starts the command, copying its output to stdout and stderr
+func (c *Command) Start(stdout, stderr io.Writer) error {
+	c.cmd.Stdout = &limitedWriter{c: c, w: stdout}
+	c.cmd.Stderr = &limitedWriter{c: c, w: stderr}
+	if err := c.cmd.Start(); err != nil {
+		return err
+	}
+
+	commandsMu.Lock()
+	commands[c.ID] = c
+	commandsMu.Unlock()
+
+	if c.limits.Timeout > 0 {
+		c.timer = time.AfterFunc(c.limits.Timeout, func() {
+			c.kill(KillTimeout)
+		})
+	}
+	return nil
+}
+
+// Wait waits for the command to finish and returns its exit code
+func (c *Command) Wait() int {
+	err := c.cmd.Wait()
+	if c.timer != nil {
+		c.timer.Stop()
+	}
+
+	commandsMu.Lock()
+	delete(commands, c.ID)
+	commandsMu.Unlock()
+
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return exitErr.ExitCode()
+		}
+		if errors.Is(err, exec.ErrWaitDelay) {
+			return c.cmd.ProcessState.ExitCode()
+		}
+		return -1
+	}
+	return 0
+}
+
+// Cancel kills the command and its whole process group
+func (c *Command) Cancel() error {
+	return c.kill(KillCanceled)
+}
+
+// Canceled reports whether the command was canceled by the client
+func (c *Command) Canceled() bool {
+	return c.KillReason() == KillCanceled
+}
+
+// KillReason returns why the command was killed, or "" if it wasn't
+func (c *Command) KillReason() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.killReason
+}
+
+// Truncated reports whether output was cut off at the output limit
+func (c *Command) Truncated() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.truncated
+}
+
+// kill kills the process group, recording the first reason given
+func (c *Command) kill(reason string) error {
+	c.mu.Lock()
+	if c.killReason == "" {
+		c.killReason = reason
+	}
+	c.mu.Unlock()
+	return killProcessGroup(c.cmd)
+}
+
+// CancelCommand kills the running command with the given ID
+func CancelCommand(id string) error {
+	commandsMu.Lock()
+	c, ok := commands[id]
+	commandsMu.Unlock()
+	if !ok {
+		return ErrCommandNotFound
+	}
+	return c.Cancel()
+}
+
+// limitedWriter passes output through until the command's combined stdout
+// and stderr reach the output limit, then kills the command
+type limitedWriter struct {
+	c *Command
+	w io.Writer
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if max := l.c.limits.MaxOutput; max > 0 {
+		l.c.mu.Lock()
+		if remaining := max - l.c.written; int64(len(p)) > remaining {
+			p = p[:remaining]
+			l.c.truncated = true
+		}
+		l.c.written += int64(len(p))
+		truncated := l.c.truncated
+		l.c.mu.Unlock()
+
+		if truncated {
+			defer l.c.kill(KillOutputLimit)
+		}
+	}
+	if len(p) > 0 {
+		if _, err := l.w.Write(p); err != nil {
+			return 0, err
+		}
+	}
+	// Report the full length so the pipe keeps draining until the kill lands
+	return n, nil
+}
@@ -0,0 +1,38 @@
+//go:build !windows
+
+package terminal
+
+import (
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup runs cmd in its own process group so it can be killed
+// together with any children it forks
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills cmd and every process in its group
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
+
+// withRlimits prefixes command with ulimit calls for the CPU and memory
+// limits. If one fails the shell exits with 126 and ulimit's error instead
+// of running command without it; "||" rather than "&&" so a list in
+// command can't run its later parts anyway.
+func withRlimits(command string, limits Limits) string {
+	prefix := ""
+	if limits.CPUSeconds > 0 {
+		prefix += fmt.Sprintf("ulimit -t %d || exit 126; ", limits.CPUSeconds)
+	}
+	if limits.MemoryBytes > 0 {
+		prefix += fmt.Sprintf("ulimit -v %d || exit 126; ", limits.MemoryBytes/1024)
+	}
+	return prefix + command
+}
@@ -0,0 +1,27 @@
+//go:build windows
+
+package terminal
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// setProcessGroup is a no-op on Windows; killProcessGroup uses taskkill /T instead
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills cmd and its child processes
+func killProcessGroup(cmd *exec.Cmd) error {
+	if cmd.Process == nil {
+		return nil
+	}
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
+
+// withRlimits returns command unchanged; rlimits aren't available on Windows
+func withRlimits(command string, limits Limits) string {
+	return command
+}
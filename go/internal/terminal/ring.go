@@ -0,0 +1,49 @@
+package terminal
+
+import "unicode/utf8"
+
+// ring is a fixed-size byte buffer that keeps only the most recent output
+type ring struct {
+	data []byte
+	pos  int
+	full bool
+}
+
+func newRing(size int) *ring {
+	return &ring{data: make([]byte, size)}
+}
+
+// Write appends p, overwriting the oldest bytes when the buffer is full
+func (r *ring) Write(p []byte) {
+	if len(p) >= len(r.data) {
+		copy(r.data, p[len(p)-len(r.data):])
+		r.pos = 0
+		r.full = true
+		return
+	}
+	n := copy(r.data[r.pos:], p)
+	if n < len(p) {
+		copy(r.data, p[n:])
+		r.full = true
+	}
+	r.pos = (r.pos + len(p)) % len(r.data)
+	if r.pos == 0 && len(p) > 0 {
+		r.full = true
+	}
+}
+
+// Bytes returns a copy of the buffered output, oldest first
+func (r *ring) Bytes() []byte {
+	if !r.full {
+		return append([]byte(nil), r.data[:r.pos]...)
+	}
+	out := make([]byte, 0, len(r.data))
+	out = append(out, r.data[r.pos:]...)
+	out = append(out, r.data[:r.pos]...)
+
+	// Drop a partial rune left behind by overwriting
+	for len(out) > 0 && !utf8.RuneStart(out[0]) {
+		out = out[1:]
+	}
+	return out
+}
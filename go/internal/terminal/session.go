@@ -0,0 +1,318 @@
+package terminal
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"os"
+	"os/exec"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+
+	"github.com/c00d-ide/c00d/internal/db"
+	"github.com/c00d-ide/c00d/internal/fileutil"
+)
+
+const (
+	// scrollbackSize is how much recent output is kept for replay on attach
+	scrollbackSize = 256 * 1024
+
+	// detachedTimeout is how long a session may run with no clients attached
+	detachedTimeout = 24 * time.Hour
+
+	// clientBuffer is how many output chunks may queue for a slow client
+	// before it is disconnected
+	clientBuffer = 256
+)
+
+// ErrNotFound is returned when a session ID doesn't match a live session
+var ErrNotFound = errors.New("session not found")
+
+// Session is a PTY shell that keeps running while clients attach and detach
+type Session struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Cwd       string    `json:"cwd"`
+	PID       int       `json:"pid"`
+	CreatedAt time.Time `json:"created_at"`
+
+	cmd  *exec.Cmd
+	ptmx *os.File
+	done chan struct{}
+
+	mu         sync.Mutex
+	scrollback *ring
+	clients    map[chan []byte]struct{}
+	detachedAt time.Time
+	exitCode   int
+	signal     int // Signal that ended the shell, if any
+	killed     bool
+}
+
+var (
+	sessionsMu sync.Mutex
+	sessions   = map[string]*Session{}
+)
+
+// Create starts a new shell session in dir
+func Create(name, dir string, cols, rows uint16) (*Session, error) {
+	token := make([]byte, 8)
+	rand.Read(token)
+	id := hex.EncodeToString(token)
+	if name == "" {
+		name = "shell-" + id[:4]
+	}
+
+	cmd := exec.Command(Shell())
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "TERM=xterm-256color", "COLORTERM=truecolor", "C00D_SESSION="+id)
+
+	ptmx, err := pty.StartWithSize(cmd, &pty.Winsize{Cols: cols, Rows: rows})
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{
+		ID:         id,
+		Name:       name,
+		Cwd:        dir,
+		PID:        cmd.Process.Pid,
+		CreatedAt:  time.Now(),
+		cmd:        cmd,
+		ptmx:       ptmx,
+		done:       make(chan struct{}),
+		scrollback: newRing(scrollbackSize),
+		clients:    map[chan []byte]struct{}{},
+		detachedAt: time.Now(),
+	}
+
+	sessionsMu.Lock()
+	sessions[id] = s
+	sessionsMu.Unlock()
+
+	db.CreateTerminalSession(id, name, dir, s.PID)
+
+	go s.readLoop()
+	return s, nil
+}
+
+// Get returns the live session with the given ID
+func Get(id string) (*Session, error) {
+	sessionsMu.Lock()
+	defer sessionsMu.Unlock()
+	s, ok := sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return s, nil
+}
+
+// List returns all live sessions, oldest first
+func List() []*Session {
+	sessionsMu.Lock()
+	list := make([]*Session, 0, len(sessions))
+	for _, s := range sessions {
+		list = append(list, s)
+	}
+	sessionsMu.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].CreatedAt.Before(list[j].CreatedAt)
+	})
+	return list
+}
+
+// Kill terminates the session with the given ID
+func Kill(id string) error {
+	s, err := Get(id)
+	if err != nil {
+		return err
+	}
+	s.Kill()
+	return nil
+}
+
+// StartCleanupRoutine marks sessions orphaned by a previous server process
+// and starts a background goroutine that kills long-detached sessions
+func StartCleanupRoutine() {
+	db.MarkLostTerminalSessions()
+
+	go func() {
+		for {
+			time.Sleep(time.Minute)
+			for _, s := range List() {
+				s.mu.Lock()
+				idle := len(s.clients) == 0 && time.Since(s.detachedAt) > detachedTimeout
+				s.mu.Unlock()
+				if idle {
+					s.Kill()
+				}
+			}
+		}
+	}()
+}
+
+// Shell returns the shell to run in interactive terminals
+func Shell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	if _, err := exec.LookPath("bash"); err == nil {
+		return "bash"
+	}
+	return "sh"
+}
+
+// Attach subscribes to session output. It returns the scrollback to replay,
+// a channel of new output and a function to detach. The channel is closed
+// when the session exits, when the client is too slow to keep up, or on detach.
+func (s *Session) Attach() ([]byte, <-chan []byte, func()) {
+	ch := make(chan []byte, clientBuffer)
+
+	s.mu.Lock()
+	replay := s.scrollback.Bytes()
+	select {
+	case <-s.done:
+		close(ch)
+	default:
+		s.clients[ch] = struct{}{}
+	}
+	s.mu.Unlock()
+
+	db.TouchTerminalSession(s.ID)
+
+	detach := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := s.clients[ch]; ok {
+			delete(s.clients, ch)
+			close(ch)
+			if len(s.clients) == 0 {
+				s.detachedAt = time.Now()
+			}
+		}
+	}
+	return replay, ch, detach
+}
+
+// Write sends input to the shell
+func (s *Session) Write(p []byte) (int, error) {
+	return s.ptmx.Write(p)
+}
+
+// Resize changes the terminal window size
+func (s *Session) Resize(cols, rows uint16) error {
+	return pty.Setsize(s.ptmx, &pty.Winsize{Cols: cols, Rows: rows})
+}
+
+// Clients returns the number of attached clients
+func (s *Session) Clients() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.clients)
+}
+
+// Done is closed when the shell exits
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// ExitCode returns the shell's exit code once Done is closed
+func (s *Session) ExitCode() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exitCode
+}
+
+// Signal returns the number of the signal that ended the shell once Done
+// is closed, or 0 if it exited by itself
+func (s *Session) Signal() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.signal
+}
+
+// Kill hangs up the terminal and stops the shell
+func (s *Session) Kill() {
+	s.mu.Lock()
+	s.killed = true
+	s.mu.Unlock()
+
+	s.ptmx.Close()
+	s.cmd.Process.Kill()
+}
+
+// readLoop copies PTY output into the scrollback and to attached clients
+// until the shell exits
+func (s *Session) readLoop() {
+	buf := make([]byte, 32*1024)
+	var pending []byte
+	for {
+		n, err := s.ptmx.Read(buf)
+		if n > 0 {
+			var out []byte
+			out, pending = fileutil.SplitUTF8(append(pending, buf[:n]...))
+			if len(out) > 0 {
+				s.broadcast(append([]byte(nil), out...))
+			}
+		}
+		if err != nil {
+			break
+		}
+	}
+
+	exitCode, signal := 0, 0
+	if err := s.cmd.Wait(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			exitCode = exitErr.ExitCode()
+			if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+				signal = int(status.Signal())
+			}
+		}
+	}
+	s.ptmx.Close()
+
+	sessionsMu.Lock()
+	delete(sessions, s.ID)
+	sessionsMu.Unlock()
+
+	s.mu.Lock()
+	s.exitCode = exitCode
+	s.signal = signal
+	status := "exited"
+	if s.killed {
+		status = "killed"
+	}
+	close(s.done)
+	for ch := range s.clients {
+		delete(s.clients, ch)
+		close(ch)
+	}
+	s.mu.Unlock()
+
+	db.CloseTerminalSession(s.ID, status, exitCode)
+}
+
+// broadcast records output and fans it out to attached clients
+func (s *Session) broadcast(p []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scrollback.Write(p)
+	for ch := range s.clients {
+		select {
+		case ch <- p:
+		default:
+			// Client can't keep up; drop it so it reattaches and replays
+			delete(s.clients, ch)
+			close(ch)
+			if len(s.clients) == 0 {
+				s.detachedAt = time.Now()
+			}
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package trash
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/c00d-ide/c00d/internal/config"
+	"github.com/c00d-ide/c00d/internal/db"
+	"github.com/c00d-ide/c00d/internal/fileutil"
+	"github.com/c00d-ide/c00d/internal/security"
+	"github.com/c00d-ide/c00d/internal/workspace"
+)
+
+var (
+	// ErrNotFound is returned when a trash item doesn't exist
+	ErrNotFound = errors.New("trash item not found")
+	// ErrExists is returned when restoring over a path that exists again
+	ErrExists = errors.New("a file already exists at the original path")
+	// ErrProtected is returned when deleting a workspace root or a data directory
+	ErrProtected = errors.New("cannot delete this path")
+)
+
+// dir is where trashed items are kept, each named by its id
+func dir() string {
+	return filepath.Join(config.C.DataDir, "trash")
+}
+
+// Protected reports whether fullPath is a workspace root or contains a data
+// directory, which must never be deleted through the IDE
+func Protected(fullPath string) bool {
+	fullPath = filepath.Clean(fullPath)
+	if security.WithinDir(fullPath, config.C.DataDir) {
+		return true
+	}
+	for _, ws := range workspace.List() {
+		if fullPath == ws.Path || security.WithinDir(fullPath, ws.DataDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// Move moves fullPath into the trash, recording relPath under the workspace
+// root as where it came from
+func Move(root, relPath, fullPath string) (*db.TrashItem, error) {
+	if Protected(fullPath) {
+		return nil, ErrProtected
+	}
+	info, err := os.Lstat(fullPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir(), 0700); err != nil {
+		return nil, err
+	}
+
+	token := make([]byte, 8)
+	rand.Read(token)
+	id := hex.EncodeToString(token)
+
+	size := info.Size()
+	if info.IsDir() {
+		size = treeSize(fullPath)
+	}
+	if err := fileutil.Move(fullPath, filepath.Join(dir(), id)); err != nil {
+		return nil, err
+	}
+	if err := db.AddTrashItem(id, root, relPath, info.IsDir(), size); err != nil {
+		// Put it back rather than leave an item nobody can find
+		fileutil.Move(filepath.Join(dir(), id), fullPath)
+		return nil, err
+	}
+	return db.GetTrashItem(id)
+}
+
+// Restore moves a trash item back to fullPath, refusing to overwrite
+// anything that exists there now
+func Restore(id, fullPath string) error {
+	if _, err := db.GetTrashItem(id); err != nil {
+		return ErrNotFound
+	}
+	if _, err := os.Lstat(fullPath); err == nil {
+		return ErrExists
+	}
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		return err
+	}
+	if err := fileutil.Move(filepath.Join(dir(), id), fullPath); err != nil {
+		return err
+	}
+	db.DeleteTrashItem(id)
+	return nil
+}
+
+// Purge permanently deletes a trash item
+func Purge(id string) error {
+	if _, err := db.GetTrashItem(id); err != nil {
+		return ErrNotFound
+	}
+	if err := os.RemoveAll(filepath.Join(dir(), id)); err != nil {
+		return err
+	}
+	db.DeleteTrashItem(id)
+	return nil
+}
+
+// PurgeOlderThan permanently deletes items trashed from root, or from any
+// workspace if root is empty, before cutoff, or every item if cutoff is
+// zero. It returns how many were deleted.
+func PurgeOlderThan(root string, cutoff time.Time) int {
+	items, err := db.GetTrashItems(root, cutoff)
+	if err != nil {
+		return 0
+	}
+	purged := 0
+	for _, item := range items {
+		if Purge(item.ID) == nil {
+			purged++
+		}
+	}
+	return purged
+}
+
+// StartCleanupRoutine purges expired items every hour
+func StartCleanupRoutine() {
+	go func() {
+		for {
+			if days := config.C.Files.Trash.MaxDays; days > 0 {
+				PurgeOlderThan("", time.Now().AddDate(0, 0, -days))
+			}
+			time.Sleep(1 * time.Hour)
+		}
+	}()
+}
+
+func treeSize(root string) int64 {
+	var size int64
+	filepath.WalkDir(root, func(_ string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+	return size
+}
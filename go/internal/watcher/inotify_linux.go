@@ -0,0 +1,170 @@
+//go:build linux
+
+package watcher
+
+import (
+	"io/fs"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+const inotifyMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MODIFY |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF
+
+// inotify watches every directory in the tree with a single inotify instance
+type inotify struct {
+	fd int
+	w  *Watcher
+
+	mu    sync.Mutex
+	paths map[int32]string // watch descriptor -> directory
+	wds   map[string]int32 // directory -> watch descriptor
+}
+
+// startInotify starts the inotify backend
+func (w *Watcher) startInotify() error {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC)
+	if err != nil {
+		return err
+	}
+
+	in := &inotify{
+		fd:    fd,
+		w:     w,
+		paths: map[int32]string{},
+		wds:   map[string]int32{},
+	}
+	if err := in.addTree(w.Root, false); err != nil {
+		syscall.Close(fd)
+		return err
+	}
+
+	w.Mode = "inotify"
+	go in.readLoop()
+	return nil
+}
+
+// addTree watches dir and every directory below it. With emit set, it also
+// reports everything found as created, since entries inside a new directory
+// may appear before its watch is in place.
+func (in *inotify) addTree(dir string, emit bool) error {
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if path != in.w.Root && in.w.ignored(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if emit && path != dir {
+			in.w.emit(rawEvent{op: Create, path: path, isDir: d.IsDir()})
+		}
+		if !d.IsDir() {
+			return nil
+		}
+
+		wd, err := syscall.InotifyAddWatch(in.fd, path, inotifyMask)
+		if err != nil {
+			if err == syscall.ENOSPC {
+				// Out of watches; fail the initial scan so we can fall back to polling
+				if !emit {
+					return err
+				}
+				log.Printf("watcher: inotify watch limit reached, not watching %s", path)
+			}
+			return nil
+		}
+
+		in.mu.Lock()
+		in.paths[int32(wd)] = path
+		in.wds[path] = int32(wd)
+		in.mu.Unlock()
+		return nil
+	})
+}
+
+// removeTree stops watching dir and everything below it
+func (in *inotify) removeTree(dir string) {
+	in.mu.Lock()
+	defer in.mu.Unlock()
+	for path, wd := range in.wds {
+		if path == dir || strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			syscall.InotifyRmWatch(in.fd, uint32(wd))
+			delete(in.wds, path)
+			delete(in.paths, wd)
+		}
+	}
+}
+
+// readLoop decodes inotify events until the descriptor is closed
+func (in *inotify) readLoop() {
+	buf := make([]byte, 64*1024)
+	for {
+		n, err := syscall.Read(in.fd, buf)
+		if err != nil {
+			if err == syscall.EINTR {
+				continue
+			}
+			log.Printf("watcher: inotify read failed: %v", err)
+			return
+		}
+
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+int(ev.Len)]), "\x00")
+			offset = nameStart + int(ev.Len)
+
+			in.handle(ev.Wd, ev.Mask, ev.Cookie, name)
+		}
+	}
+}
+
+func (in *inotify) handle(wd int32, mask, cookie uint32, name string) {
+	if mask&syscall.IN_Q_OVERFLOW != 0 {
+		in.w.emit(rawEvent{op: Overflow})
+		return
+	}
+
+	in.mu.Lock()
+	dir, ok := in.paths[wd]
+	if ok && mask&syscall.IN_IGNORED != 0 {
+		delete(in.paths, wd)
+		delete(in.wds, dir)
+	}
+	in.mu.Unlock()
+	if !ok || name == "" {
+		return
+	}
+
+	path := filepath.Join(dir, name)
+	isDir := mask&syscall.IN_ISDIR != 0
+
+	switch {
+	case mask&syscall.IN_CREATE != 0:
+		in.w.emit(rawEvent{op: Create, path: path, isDir: isDir})
+		if isDir {
+			in.addTree(path, true)
+		}
+	case mask&syscall.IN_MODIFY != 0:
+		in.w.emit(rawEvent{op: Modify, path: path, isDir: isDir})
+	case mask&syscall.IN_DELETE != 0:
+		in.w.emit(rawEvent{op: Delete, path: path, isDir: isDir})
+	case mask&syscall.IN_MOVED_FROM != 0:
+		in.w.emit(rawEvent{op: "moved_from", path: path, isDir: isDir, cookie: cookie})
+		if isDir {
+			in.removeTree(path)
+		}
+	case mask&syscall.IN_MOVED_TO != 0:
+		in.w.emit(rawEvent{op: "moved_to", path: path, isDir: isDir, cookie: cookie})
+		if isDir {
+			in.addTree(path, false)
+		}
+	}
+}
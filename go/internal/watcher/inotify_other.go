@@ -0,0 +1,10 @@
+//go:build !linux
+
+package watcher
+
+import "errors"
+
+// startInotify is only available on Linux; other platforms poll
+func (w *Watcher) startInotify() error {
+	return errors.New("inotify is not supported on this platform")
+}
@@ -0,0 +1,69 @@
+package watcher
+
+import (
+	"io/fs"
+	"path/filepath"
+	"time"
+)
+
+// pollInterval is how often the polling backend rescans the tree
+const pollInterval = 2 * time.Second
+
+// fileState is what the poller compares between scans
+type fileState struct {
+	modTime time.Time
+	size    int64
+	isDir   bool
+}
+
+// startPolling starts the polling backend, which rescans the tree and
+// reports differences. It can't detect renames; they show up as a delete
+// and a create.
+func (w *Watcher) startPolling() {
+	w.Mode = "poll"
+	go func() {
+		prev := w.scan()
+		for {
+			time.Sleep(pollInterval)
+			cur := w.scan()
+			for path, state := range cur {
+				old, ok := prev[path]
+				switch {
+				case !ok:
+					w.emit(rawEvent{op: Create, path: path, isDir: state.isDir})
+				case !state.isDir && (old.modTime != state.modTime || old.size != state.size):
+					w.emit(rawEvent{op: Modify, path: path})
+				}
+			}
+			for path, state := range prev {
+				if _, ok := cur[path]; !ok {
+					w.emit(rawEvent{op: Delete, path: path, isDir: state.isDir})
+				}
+			}
+			prev = cur
+		}
+	}()
+}
+
+// scan records the state of every watched path
+func (w *Watcher) scan() map[string]fileState {
+	states := map[string]fileState{}
+	filepath.WalkDir(w.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == w.Root {
+			return nil
+		}
+		if w.ignored(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		states[path] = fileState{modTime: info.ModTime(), size: info.Size(), isDir: d.IsDir()}
+		return nil
+	})
+	return states
+}
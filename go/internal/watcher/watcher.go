@@ -0,0 +1,263 @@
+package watcher
+
+import (
+	"log"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/c00d-ide/c00d/internal/ignore"
+)
+
+const (
+	// debounceDelay is how long events are collected before a batch is sent
+	debounceDelay = 200 * time.Millisecond
+
+	// subscriberBuffer is how many batches may queue for a slow subscriber
+	subscriberBuffer = 64
+)
+
+// Event types
+const (
+	Create   = "create"
+	Modify   = "modify"
+	Delete   = "delete"
+	Rename   = "rename"
+	Overflow = "overflow" // Events were lost; clients should reload
+)
+
+// Event describes a change to a path relative to the watched root
+type Event struct {
+	Type    string `json:"type"`
+	Path    string `json:"path"`
+	OldPath string `json:"old_path,omitempty"`
+	IsDir   bool   `json:"is_dir"`
+}
+
+// rawEvent is a change reported by a backend before debouncing
+type rawEvent struct {
+	op     string // create, modify, delete, moved_from, moved_to, overflow
+	path   string // absolute
+	isDir  bool
+	cookie uint32 // pairs moved_from with moved_to
+}
+
+// Watcher watches a directory tree and fans debounced change batches out
+// to subscribers
+type Watcher struct {
+	Root string
+	Mode string // inotify or poll
+
+	raw chan rawEvent
+
+	mu   sync.Mutex
+	subs map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	ch       chan []Event
+	overflow bool
+}
+
+var (
+	watchersMu sync.Mutex
+	watchers   = map[string]*Watcher{}
+)
+
+// For returns the watcher for root, starting it on first use
+func For(root string) *Watcher {
+	watchersMu.Lock()
+	defer watchersMu.Unlock()
+
+	if w, ok := watchers[root]; ok {
+		return w
+	}
+
+	w := &Watcher{
+		Root: root,
+		raw:  make(chan rawEvent, 1024),
+		subs: map[*subscriber]struct{}{},
+	}
+	if err := w.startInotify(); err != nil {
+		log.Printf("watcher: inotify unavailable for %s (%v), polling instead", root, err)
+		w.startPolling()
+	}
+	go w.loop()
+
+	watchers[root] = w
+	return w
+}
+
+// Subscribe returns a channel of event batches and a function to unsubscribe
+func (w *Watcher) Subscribe() (<-chan []Event, func()) {
+	sub := &subscriber{ch: make(chan []Event, subscriberBuffer)}
+
+	w.mu.Lock()
+	w.subs[sub] = struct{}{}
+	w.mu.Unlock()
+
+	unsubscribe := func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if _, ok := w.subs[sub]; ok {
+			delete(w.subs, sub)
+			close(sub.ch)
+		}
+	}
+	return sub.ch, unsubscribe
+}
+
+// ignored reports whether an absolute path is excluded from events. These
+// are the same paths the file listing and search leave out. Since hidden
+// directories are never watched, only the path itself needs checking.
+func (w *Watcher) ignored(path string, isDir bool) bool {
+	return ignore.For(w.Root).MatchPath(path, isDir)
+}
+
+// emit queues a raw event from a backend
+func (w *Watcher) emit(ev rawEvent) {
+	if ev.op != Overflow && w.ignored(ev.path, ev.isDir) {
+		return
+	}
+	w.raw <- ev
+}
+
+// loop collects raw events into debounced batches
+func (w *Watcher) loop() {
+	var batch *eventBatch
+	var timer <-chan time.Time
+
+	for {
+		select {
+		case ev := <-w.raw:
+			if batch == nil {
+				batch = newEventBatch()
+				timer = time.After(debounceDelay)
+			}
+			batch.add(w.rel(ev.path), ev)
+
+		case <-timer:
+			if events := batch.events(); len(events) > 0 {
+				w.broadcast(events)
+			}
+			batch = nil
+			timer = nil
+		}
+	}
+}
+
+// broadcast sends a batch to every subscriber without blocking on slow ones
+func (w *Watcher) broadcast(events []Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for sub := range w.subs {
+		batch := events
+		if sub.overflow {
+			batch = append([]Event{{Type: Overflow}}, events...)
+		}
+		select {
+		case sub.ch <- batch:
+			sub.overflow = false
+		default:
+			sub.overflow = true
+		}
+	}
+}
+
+// rel converts an absolute path to a slash-separated path relative to the root
+func (w *Watcher) rel(path string) string {
+	rel, err := filepath.Rel(w.Root, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// eventBatch coalesces raw events for the same path within a debounce window
+type eventBatch struct {
+	order    []string
+	pending  map[string]*Event
+	moves    map[uint32]*Event // moved_from events awaiting their moved_to
+	overflow bool
+}
+
+func newEventBatch() *eventBatch {
+	return &eventBatch{pending: map[string]*Event{}, moves: map[uint32]*Event{}}
+}
+
+func (b *eventBatch) add(path string, ev rawEvent) {
+	prev := b.pending[path]
+
+	switch ev.op {
+	case Overflow:
+		b.overflow = true
+
+	case Create:
+		if prev != nil && prev.Type == Delete {
+			prev.Type = Modify
+			return
+		}
+		b.set(path, &Event{Type: Create, Path: path, IsDir: ev.isDir})
+
+	case Modify:
+		if prev != nil {
+			return // create, rename and modify already cover it
+		}
+		b.set(path, &Event{Type: Modify, Path: path, IsDir: ev.isDir})
+
+	case Delete:
+		if prev != nil && prev.Type == Create {
+			delete(b.pending, path)
+			return
+		}
+		b.set(path, &Event{Type: Delete, Path: path, IsDir: ev.isDir})
+
+	case "moved_from":
+		b.moves[ev.cookie] = &Event{Type: Delete, Path: path, IsDir: ev.isDir}
+
+	case "moved_to":
+		from, ok := b.moves[ev.cookie]
+		if !ok {
+			// Moved in from outside the tree
+			b.set(path, &Event{Type: Create, Path: path, IsDir: ev.isDir})
+			return
+		}
+		delete(b.moves, ev.cookie)
+		if p := b.pending[from.Path]; p != nil && p.Type == Create {
+			// Created and renamed within the window: just a create
+			delete(b.pending, from.Path)
+			b.set(path, &Event{Type: Create, Path: path, IsDir: ev.isDir})
+			return
+		}
+		delete(b.pending, from.Path)
+		b.set(path, &Event{Type: Rename, Path: path, OldPath: from.Path, IsDir: ev.isDir})
+	}
+}
+
+func (b *eventBatch) set(path string, ev *Event) {
+	if _, ok := b.pending[path]; !ok {
+		b.order = append(b.order, path)
+	}
+	b.pending[path] = ev
+}
+
+// events returns the coalesced events in the order they were first seen.
+// Moves out of the tree are reported as deletes.
+func (b *eventBatch) events() []Event {
+	events := []Event{}
+	if b.overflow {
+		events = append(events, Event{Type: Overflow})
+	}
+	seen := map[string]bool{}
+	for _, path := range b.order {
+		if ev, ok := b.pending[path]; ok && !seen[path] {
+			seen[path] = true
+			events = append(events, *ev)
+		}
+	}
+	for _, ev := range b.moves {
+		events = append(events, *ev)
+	}
+	return events
+}
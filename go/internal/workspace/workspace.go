@@ -0,0 +1,236 @@
+package workspace
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/c00d-ide/c00d/internal/config"
+	"github.com/c00d-ide/c00d/internal/fileutil"
+	"github.com/c00d-ide/c00d/internal/security"
+)
+
+// CookieName holds the workspace chosen in the editor
+const CookieName = "c00d_workspace"
+
+// Workspace is a project root served by this instance, with its settings
+// resolved against the global ones
+type Workspace struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Path    string `json:"path"`
+	Default bool   `json:"default"`
+
+	DataDir    string   `json:"-"` // Backups of its files
+	ShowHidden bool     `json:"-"`
+	Exclude    []string `json:"-"`
+	Gitignore  bool     `json:"-"`
+}
+
+var (
+	list []*Workspace
+	byID = map[string]*Workspace{}
+)
+
+// Init builds the workspace list from config: base_path first as the
+// default workspace, then the workspaces list. Listing base_path there too
+// names it and sets its options. Workspaces whose path isn't a directory
+// are skipped.
+func Init() {
+	list = nil
+	byID = map[string]*Workspace{}
+
+	primary := config.Workspace{Path: config.C.BasePath}
+	listed := false
+	var others []config.Workspace
+	for _, c := range config.C.Workspaces {
+		c.Path, _ = filepath.Abs(c.Path)
+		if c.Path == config.C.BasePath && !listed {
+			primary, listed = c, true
+			continue
+		}
+		others = append(others, c)
+	}
+	// The default workspace keeps its data with everything else
+	primary.DataDir = config.C.DataDir
+	add(primary, true)
+
+	for _, c := range others {
+		if info, err := os.Stat(c.Path); err != nil || !info.IsDir() {
+			log.Printf("workspace %q skipped: %s is not a directory", c.Name, c.Path)
+			continue
+		}
+		add(c, false)
+	}
+}
+
+func add(c config.Workspace, isDefault bool) {
+	ws := &Workspace{
+		Name:       c.Name,
+		Path:       c.Path,
+		Default:    isDefault,
+		DataDir:    c.DataDir,
+		ShowHidden: config.C.Files.ShowHidden,
+		Exclude:    config.C.Files.Exclude,
+		Gitignore:  config.C.Files.Gitignore,
+	}
+	if ws.Name == "" {
+		ws.Name = filepath.Base(c.Path)
+	}
+	if ws.DataDir == "" {
+		ws.DataDir = filepath.Join(c.Path, ".c00d")
+	}
+	ws.DataDir, _ = filepath.Abs(ws.DataDir)
+	if c.ShowHidden != nil {
+		ws.ShowHidden = *c.ShowHidden
+	}
+	if c.Exclude != nil {
+		ws.Exclude = c.Exclude
+	}
+	if c.Gitignore != nil {
+		ws.Gitignore = *c.Gitignore
+	}
+
+	// IDs go in URLs and cookies; keep them simple and unique
+	id := slug(c.ID)
+	if id == "" {
+		id = slug(ws.Name)
+	}
+	if id == "" {
+		id = "workspace"
+	}
+	ws.ID = id
+	for n := 2; byID[ws.ID] != nil; n++ {
+		ws.ID = id + "-" + strconv.Itoa(n)
+	}
+
+	list = append(list, ws)
+	byID[ws.ID] = ws
+}
+
+// slug lowercases s and replaces anything but letters, digits, "-" and "_"
+func slug(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '_':
+			b.WriteRune(r)
+		default:
+			b.WriteRune('-')
+		}
+	}
+	return strings.Trim(b.String(), "-")
+}
+
+// List returns every workspace, the default one first
+func List() []*Workspace {
+	return list
+}
+
+// Get returns the workspace with the given ID, or nil
+func Get(id string) *Workspace {
+	return byID[id]
+}
+
+// Default returns the workspace of base_path
+func Default() *Workspace {
+	if len(list) == 0 {
+		Init()
+	}
+	return list[0]
+}
+
+// ForRoot returns the workspace rooted at root, or nil
+func ForRoot(root string) *Workspace {
+	for _, ws := range list {
+		if ws.Path == root {
+			return ws
+		}
+	}
+	return nil
+}
+
+type contextKey struct{}
+
+// Middleware resolves the workspace of a request from the "workspace"
+// query parameter, the X-Workspace header or the workspace cookie, in that
+// order, falling back to the default one. An unknown ID is an error unless
+// it comes from the cookie, which may predate a config change.
+func Middleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("workspace")
+		if id == "" {
+			id = r.Header.Get("X-Workspace")
+		}
+		ws := Default()
+		if id != "" {
+			if ws = Get(id); ws == nil {
+				w.Header().Set("Content-Type", "application/json")
+				http.Error(w, `{"error":"unknown workspace"}`, http.StatusNotFound)
+				return
+			}
+		} else if cookie, err := r.Cookie(CookieName); err == nil && Get(cookie.Value) != nil {
+			ws = Get(cookie.Value)
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), contextKey{}, ws)))
+	}
+}
+
+// From returns the workspace of a request
+func From(r *http.Request) *Workspace {
+	if ws, ok := r.Context().Value(contextKey{}).(*Workspace); ok {
+		return ws
+	}
+	return Default()
+}
+
+// ValidatePath checks that path, relative to the workspace root, stays
+// within it, following symlinks, and returns it joined to the root
+func (ws *Workspace) ValidatePath(path string) (string, bool) {
+	fullPath, err := security.Resolve(ws.Path, path, true)
+	return fullPath, err == nil
+}
+
+// ValidateLinkPath is like ValidatePath but doesn't follow a symlink in the
+// last component, for operations on the link itself such as deleting or
+// renaming it
+func (ws *Workspace) ValidateLinkPath(path string) (string, bool) {
+	fullPath, err := security.Resolve(ws.Path, path, false)
+	return fullPath, err == nil
+}
+
+// ValidateFullPath checks that an absolute path is within the workspace
+// root, following symlinks
+func (ws *Workspace) ValidateFullPath(fullPath string) bool {
+	return security.Check(ws.Path, fullPath, true) == nil
+}
+
+// Protected reports whether fullPath is in a data directory, where the
+// database, backups, history and trash live and nothing may be written
+// through the file API. Symlinks are resolved, so a link into a data
+// directory is protected too.
+func (ws *Workspace) Protected(fullPath string) bool {
+	real := fileutil.RealPath(fullPath)
+	for _, dir := range []string{config.C.DataDir, ws.DataDir} {
+		if dir == "" {
+			continue
+		}
+		if security.WithinDir(dir, fullPath) || security.WithinDir(fileutil.RealPath(dir), real) {
+			return true
+		}
+	}
+	return false
+}
+
+// Rel returns fullPath relative to the workspace root, slash-separated
+func (ws *Workspace) Rel(fullPath string) string {
+	rel, err := filepath.Rel(ws.Path, fullPath)
+	if err != nil {
+		return "."
+	}
+	return filepath.ToSlash(rel)
+}
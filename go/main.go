@@ -14,6 +14,11 @@ import (
 	"github.com/c00d-ide/c00d/internal/config"
 	"github.com/c00d-ide/c00d/internal/db"
 	"github.com/c00d-ide/c00d/internal/handlers"
+	"github.com/c00d-ide/c00d/internal/history"
+	"github.com/c00d-ide/c00d/internal/search"
+	"github.com/c00d-ide/c00d/internal/terminal"
+	"github.com/c00d-ide/c00d/internal/trash"
+	"github.com/c00d-ide/c00d/internal/workspace"
 )
 
 //go:embed frontend/*
@@ -43,31 +48,66 @@ func main() {
 	// Ensure data directory exists
 	os.MkdirAll(config.C.DataDir, 0755)
 
+	// Resolve the workspaces served next to the base path
+	workspace.Init()
+
 	// Initialize database
 	db.Init()
 
 	// Start session cleanup routine
 	auth.StartCleanupRoutine()
 
+	// Start terminal session cleanup routine
+	terminal.StartCleanupRoutine()
+
+	// Start file history retention routine
+	history.StartCleanupRoutine()
+
+	// Start trash expiry routine
+	trash.StartCleanupRoutine()
+
+	// Load or build search indexes in the background
+	for _, ws := range workspace.List() {
+		search.IndexFor(ws.Path)
+	}
+
 	// Set frontend filesystem for handler
 	handlers.FrontendFS = frontendFS
 
 	// Setup routes
 	mux := http.NewServeMux()
 
-	// Helper to wrap handlers with logging + auth
+	// Helper to wrap handlers with logging + auth, resolving the workspace
 	withAuth := func(h http.HandlerFunc) http.HandlerFunc {
-		return auth.WithLogging(auth.Middleware(h))
+		return auth.WithLogging(auth.Middleware(workspace.Middleware(h)))
 	}
 
 	// API routes (with logging and auth)
 	mux.HandleFunc("/api/files", withAuth(handlers.Files))
+	mux.HandleFunc("/api/tree", withAuth(handlers.Tree))
+	mux.HandleFunc("/api/find", withAuth(handlers.Find))
 	mux.HandleFunc("/api/file", withAuth(handlers.File))
+	mux.HandleFunc("/api/file/backups", withAuth(handlers.FileBackups))
+	mux.HandleFunc("/api/upload", withAuth(handlers.Upload))
+	mux.HandleFunc("/api/download", withAuth(handlers.Download))
+	mux.HandleFunc("/api/archive", withAuth(handlers.Archive))
+	mux.HandleFunc("/api/history", withAuth(handlers.History))
+	mux.HandleFunc("/api/trash", withAuth(handlers.Trash))
 	mux.HandleFunc("/api/terminal", withAuth(handlers.Terminal))
+	mux.HandleFunc("/api/terminal/cancel", withAuth(handlers.TerminalCancel))
+	mux.HandleFunc("/api/terminal/history", withAuth(handlers.TerminalHistory))
+	mux.HandleFunc("/api/terminal/ws", withAuth(handlers.TerminalWS))
+	mux.HandleFunc("/api/terminal/sessions", withAuth(handlers.TerminalSessions))
 	mux.HandleFunc("/api/ai", withAuth(handlers.AI))
 	mux.HandleFunc("/api/config", withAuth(handlers.Config))
 	mux.HandleFunc("/api/git", withAuth(handlers.Git))
 	mux.HandleFunc("/api/search", withAuth(handlers.Search))
+	mux.HandleFunc("/api/search/index", withAuth(handlers.SearchIndex))
+	mux.HandleFunc("/api/replace", withAuth(handlers.Replace))
+	mux.HandleFunc("/api/symbols", withAuth(handlers.Symbols))
+	mux.HandleFunc("/api/symbols/outline", withAuth(handlers.SymbolOutline))
+	mux.HandleFunc("/api/watch", withAuth(handlers.Watch))
+	mux.HandleFunc("/api/workspaces", withAuth(handlers.Workspaces))
 	mux.HandleFunc("/api/iplogs", withAuth(handlers.IPLogs))
 	mux.HandleFunc("/api/auth", auth.WithLogging(handlers.Auth))
 
@@ -81,7 +121,11 @@ func main() {
 	if ip := getOutboundIP(); ip != "" {
 		fmt.Printf("  Network: http://%s%s\n", ip, addr)
 	}
-	fmt.Printf("  Path:    %s\n\n", config.C.BasePath)
+	fmt.Printf("  Path:    %s\n", config.C.BasePath)
+	for _, ws := range workspace.List()[1:] {
+		fmt.Printf("  Workspace %s: %s\n", ws.ID, ws.Path)
+	}
+	fmt.Println()
 
 	if config.C.Password != "" {
 		fmt.Printf("  Password protection: enabled\n")